@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/fedekunze/alien_task/cosmos"
+	"github.com/spf13/cobra"
+)
+
+var analyzeJSON bool
+
+// analyzeCmd describes the structure of a map
+var analyzeCmd = &cobra.Command{
+	Use:   "analyze <map>",
+	Short: "Describe the cities, roads and connectivity of a map",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := AnalyzeMap(os.Stdout, args[0], analyzeJSON)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(analyzeCmd)
+	analyzeCmd.Flags().BoolVar(&analyzeJSON, "json", false, "Print the analysis as a JSON object")
+}
+
+// AnalyzeMap reads a map file and writes its analysis to w
+func AnalyzeMap(w io.Writer, filename string, asJSON bool) error {
+	m, err := createMap(config.Directions)
+	if err != nil {
+		return err
+	}
+	err = ReadMap(filename, m)
+	if err != nil {
+		return err
+	}
+	return WriteAnalysis(w, cosmos.Analyze(m), asJSON)
+}
+
+// WriteAnalysis writes the analysis of a map, one property per line, or as a
+// JSON object
+func WriteAnalysis(w io.Writer, a cosmos.Analysis, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(a)
+	}
+	fmt.Fprintf(w, "cities: %v\n", a.Cities)
+	fmt.Fprintf(w, "roads: %v\n", a.Roads)
+	degrees := make([]string, 0, len(a.Degrees))
+	for degree, cities := range a.Degrees {
+		if cities > 0 {
+			degrees = append(degrees, fmt.Sprintf("%v=%v", degree, cities))
+		}
+	}
+	fmt.Fprintf(w, "degrees: %v\n", strings.Join(degrees, " "))
+	sizes := make([]string, len(a.Components))
+	for i, size := range a.Components {
+		sizes[i] = fmt.Sprint(size)
+	}
+	fmt.Fprintf(w, "components: %v (%v)\n", len(a.Components), strings.Join(sizes, " "))
+	fmt.Fprintf(w, "articulation points: %v\n", strings.Join(a.ArticulationPoints, " "))
+	bridges := make([]string, len(a.Bridges))
+	for i, bridge := range a.Bridges {
+		bridges[i] = bridge[0] + "-" + bridge[1]
+	}
+	fmt.Fprintf(w, "bridges: %v\n", strings.Join(bridges, " "))
+	if a.DiameterExact {
+		fmt.Fprintf(w, "diameter: %v\n", a.Diameter)
+	} else {
+		fmt.Fprintf(w, "diameter: at least %v\n", a.Diameter)
+	}
+	fmt.Fprintf(w, "isolated: %v\n", strings.Join(a.Isolated, " "))
+	return nil
+}
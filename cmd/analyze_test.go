@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/fedekunze/alien_task/cosmos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeMap(t *testing.T) {
+	dir := t.TempDir()
+	filename := writeFile(t, dir, "map.txt", "Foo north=Bar east=Baz\nBar east=Qux\nBaz south=Qux\nQux east=Zed\nBee west=Ann\nOne\n")
+
+	var out bytes.Buffer
+	require.Nil(t, AnalyzeMap(&out, filename, false))
+	assert.Equal(t, `cities: 8
+roads: 6
+degrees: 0=1 1=3 2=3 3=1
+components: 3 (5 2 1)
+articulation points: Qux
+bridges: Ann-Bee Qux-Zed
+diameter: 3
+isolated: One
+`, out.String())
+
+	out.Reset()
+	require.Nil(t, AnalyzeMap(&out, filename, true))
+	var a cosmos.Analysis
+	require.Nil(t, json.Unmarshal(out.Bytes(), &a))
+	assert.Equal(t, []int{5, 2, 1}, a.Components)
+	assert.True(t, a.DiameterExact)
+
+	assert.Error(t, AnalyzeMap(&out, "missing.txt", false))
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/fedekunze/alien_task/cosmos"
+	"github.com/spf13/cobra"
+)
+
+// resumeCmd goes on with a simulation from a checkpoint
+var resumeCmd = &cobra.Command{
+	Use:   "resume <checkpoint>",
+	Short: "Resume a simulation from a checkpoint written with --checkpoint-every",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := Resume(os.Stdout, args[0])
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(resumeCmd)
+}
+
+// checkpointHeader is what a checkpoint file holds before the state of the
+// simulation: the configuration of the run and what it reported so far
+type checkpointHeader struct {
+	Config    Config
+	Destroyed []string // cities destroyed so far, in order
+	Events    int64    // bytes of the event log so far
+	MapHash   string   // hash of the map before the battle, for the history
+}
+
+// Resume goes on with the simulation of a checkpoint, with the configuration
+// it was started with, and prints its results to w as if it never stopped.
+// The event log of the run, if any, is cut back to the checkpoint and goes on
+// from there.
+func Resume(w io.Writer, filename string) error {
+	header, m, sim, err := loadCheckpoint(filename)
+	if err != nil {
+		return err
+	}
+	aliensLeft, round := sim.Progress()
+	logger.Info("Resuming simulation...", "checkpoint", filename, "round", round, "aliens", aliensLeft, "seed", sim.Seed())
+	return runBattle(w, m, sim, header)
+}
+
+// saveCheckpoint writes a checkpoint file, replacing it only once it is
+// complete so a crash never leaves a broken checkpoint behind
+func saveCheckpoint(filename string, header checkpointHeader, sim *cosmos.Simulation) error {
+	tmp := filename + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	buffered := bufio.NewWriter(f)
+	err = gob.NewEncoder(buffered).Encode(header)
+	if err == nil {
+		err = sim.WriteCheckpoint(buffered)
+	}
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	closeErr := f.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	return os.Rename(tmp, filename)
+}
+
+// loadCheckpoint reads a checkpoint file
+func loadCheckpoint(filename string) (checkpointHeader, *cosmos.Map, *cosmos.Simulation, error) {
+	var header checkpointHeader
+	f, err := os.Open(filename)
+	if err != nil {
+		return header, nil, nil, err
+	}
+	defer f.Close()
+	// the decoders read exactly their data from a buffered reader
+	buffered := bufio.NewReader(f)
+	err = gob.NewDecoder(buffered).Decode(&header)
+	if err != nil {
+		return header, nil, nil, fmt.Errorf("Couldn't read checkpoint %v: %w", filename, err)
+	}
+	m, sim, err := cosmos.ReadCheckpoint(buffered)
+	if err != nil {
+		return header, nil, nil, err
+	}
+	sim.SetLogger(logger)
+	return header, m, sim, nil
+}
+
+// checkpointFile is the name of the checkpoint of a round
+func checkpointFile(dir string, round int) string {
+	return filepath.Join(dir, fmt.Sprintf("checkpoint-%v.bin", round))
+}
+
+// openEventLog opens the event log of a run, cut back to the given size
+func openEventLog(filename string, size int64) (*os.File, error) {
+	if size == 0 {
+		return os.Create(filename)
+	}
+	f, err := os.OpenFile(filename, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err == nil && info.Size() < size {
+		err = fmt.Errorf("Event log %v is shorter than at the checkpoint", filename)
+	}
+	if err == nil {
+		err = f.Truncate(size)
+	}
+	if err == nil {
+		_, err = f.Seek(size, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
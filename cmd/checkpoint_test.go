@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResume(t *testing.T) {
+	for _, mode := range []OutputMode{SummaryOutput, JSONOutput} {
+		dir := t.TempDir()
+		cfg := Config{
+			File:            writeFile(t, dir, "grid.txt", gridText(400)),
+			Aliens:          30,
+			Seed:            9,
+			Strategy:        "random",
+			FightThreshold:  2,
+			MaxRounds:       300,
+			Output:          string(mode),
+			Events:          filepath.Join(dir, "events.jsonl"),
+			CheckpointEvery: 100,
+			CheckpointDir:   filepath.Join(dir, "checkpoints"),
+		}
+		var uninterrupted bytes.Buffer
+		require.Nil(t, RunMap(&uninterrupted, cfg))
+		events, err := os.ReadFile(cfg.Events)
+		require.Nil(t, err)
+		for _, round := range []int{100, 200} {
+			// a run stopped right after the checkpoint of the round
+			checkpoint := checkpointFile(cfg.CheckpointDir, round)
+			require.FileExists(t, checkpoint)
+			var resumed bytes.Buffer
+			require.Nil(t, Resume(&resumed, checkpoint))
+			assert.Equal(t, uninterrupted.String(), resumed.String(), mode)
+			resumedEvents, err := os.ReadFile(cfg.Events)
+			require.Nil(t, err)
+			assert.Equal(t, string(events), string(resumedEvents), mode)
+		}
+	}
+}
+
+func TestResumeErrors(t *testing.T) {
+	dir := t.TempDir()
+	err := Resume(&bytes.Buffer{}, writeFile(t, dir, "broken.bin", "not a checkpoint"))
+	assert.ErrorContains(t, err, "Couldn't read checkpoint")
+	err = Config{File: "map.txt", CheckpointEvery: -1, Output: "full", MaxRounds: 1, FightThreshold: 2, Strategy: "random"}.Validate()
+	assert.EqualError(t, err, "Rounds between checkpoints can't be negative")
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/fedekunze/alien_task/cosmos"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+var cfgFile string
+
+// Config is the effective configuration of a run, merged from the flags, the
+// ALIENS_* environment variables and the config file, in that order of
+// precedence
+type Config struct {
+	File            string `mapstructure:"file" yaml:"file"`
+	Directions      string `mapstructure:"directions" yaml:"directions"`
+	Aliens          int    `mapstructure:"N" yaml:"N"`
+	Seed            int64  `mapstructure:"seed" yaml:"seed"`
+	Strategy        string `mapstructure:"strategy" yaml:"strategy"`
+	FightThreshold  int    `mapstructure:"fight-threshold" yaml:"fight-threshold"`
+	MaxRounds       int    `mapstructure:"max-rounds" yaml:"max-rounds"`
+	Output          string `mapstructure:"output" yaml:"output"`
+	Events          string `mapstructure:"events" yaml:"events"`
+	LogLevel        string `mapstructure:"log-level" yaml:"log-level"`
+	LogFormat       string `mapstructure:"log-format" yaml:"log-format"`
+	CheckInvariants bool   `mapstructure:"check-invariants" yaml:"check-invariants"`
+	Workers         int    `mapstructure:"workers" yaml:"workers"`
+	CheckpointEvery int    `mapstructure:"checkpoint-every" yaml:"checkpoint-every"`
+	CheckpointDir   string `mapstructure:"checkpoint-dir" yaml:"checkpoint-dir"`
+	History         string `mapstructure:"history" yaml:"history"`
+}
+
+// Rules returns the rules of the simulation in the configuration
+func (cfg Config) Rules() cosmos.Config {
+	return cosmos.Config{
+		Seed:            cfg.Seed,
+		MaxRounds:       cfg.MaxRounds,
+		Strategy:        cosmos.Strategy(cfg.Strategy),
+		FightThreshold:  cfg.FightThreshold,
+		CheckInvariants: cfg.CheckInvariants,
+		Workers:         cfg.Workers,
+	}
+}
+
+// Validate checks that the configuration can run a simulation
+func (cfg Config) Validate() error {
+	if cfg.File == "" {
+		return fmt.Errorf("No map file given")
+	}
+	if cfg.Aliens < 0 {
+		return fmt.Errorf("Number of aliens can't be negative")
+	}
+	if _, err := cosmos.DirectionSetByName(cfg.Directions); err != nil {
+		return err
+	}
+	if cfg.CheckpointEvery < 0 {
+		return fmt.Errorf("Rounds between checkpoints can't be negative")
+	}
+	switch OutputMode(cfg.Output) {
+	case FullOutput, QuietOutput, SummaryOutput, JSONOutput:
+	default:
+		return fmt.Errorf("%v is not a valid output mode", cfg.Output)
+	}
+	return cfg.Rules().Validate()
+}
+
+// configCmd groups the commands to inspect the configuration
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the configuration",
+}
+
+// configShowCmd prints the effective configuration
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective configuration in the config file format",
+	Run: func(cmd *cobra.Command, args []string) {
+		err := WriteConfig(os.Stdout, config)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+// WriteConfig writes a configuration in the config file format
+func WriteConfig(w io.Writer, cfg Config) error {
+	out, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+func init() {
+	RootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configShowCmd)
+	viper.SetEnvPrefix("ALIENS")
+	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	viper.AutomaticEnv()
+}
+
+// loadConfig reads the config file, if any, and merges it with the
+// environment and the flags
+func loadConfig() (Config, error) {
+	var cfg Config
+	if cfgFile != "" {
+		viper.SetConfigFile(cfgFile)
+		err := viper.ReadInConfig()
+		if err != nil {
+			return cfg, err
+		}
+	}
+	err := viper.Unmarshal(&cfg)
+	if err != nil {
+		return cfg, err
+	}
+	// the shortcuts for the output mode win over any other source
+	if quiet {
+		cfg.Output = string(QuietOutput)
+	} else if summary {
+		cfg.Output = string(SummaryOutput)
+	}
+	return cfg, nil
+}
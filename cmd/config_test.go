@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setFlag sets a flag of the root command as if it was given in the command
+// line, until the test ends
+func setFlag(t *testing.T, name string, value string) {
+	flag := RootCmd.PersistentFlags().Lookup(name)
+	if flag == nil {
+		flag = RootCmd.Flags().Lookup(name)
+	}
+	require.NotNil(t, flag, name)
+	require.Nil(t, flag.Value.Set(value))
+	flag.Changed = true
+	t.Cleanup(func() {
+		flag.Value.Set(flag.DefValue)
+		flag.Changed = false
+	})
+}
+
+// useConfigFile makes loadConfig read the given config file, until the test
+// ends
+func useConfigFile(t *testing.T, path string) {
+	cfgFile = path
+	t.Cleanup(func() {
+		cfgFile = ""
+		// forget the values read from the file
+		viper.SetConfigType("yaml")
+		viper.ReadConfig(strings.NewReader(""))
+	})
+}
+
+func TestLoadConfig(t *testing.T) {
+	cfg, err := loadConfig()
+	require.Nil(t, err)
+	assert.Equal(t, 10, cfg.Aliens)
+	assert.Equal(t, "random", cfg.Strategy)
+	assert.Equal(t, "full", cfg.Output)
+	home, err := os.UserHomeDir()
+	require.Nil(t, err)
+	assert.Equal(t, filepath.Join(home, ".aliens", "history.db"), cfg.History)
+
+	// each layer overrides the one below it: flags, then the environment,
+	// then the config file, then the defaults of the flags
+	useConfigFile(t, writeFile(t, t.TempDir(), "aliens.yaml",
+		"N: 3\nseed: 11\nstrategy: first\nmax-rounds: 50\noutput: summary\n"))
+	cfg, err = loadConfig()
+	require.Nil(t, err)
+	assert.Equal(t, 3, cfg.Aliens)
+	assert.Equal(t, int64(11), cfg.Seed)
+	assert.Equal(t, "first", cfg.Strategy)
+	assert.Equal(t, 2, cfg.FightThreshold)
+
+	t.Setenv("ALIENS_SEED", "12")
+	t.Setenv("ALIENS_STRATEGY", "random")
+	t.Setenv("ALIENS_MAX_ROUNDS", "60")
+	cfg, err = loadConfig()
+	require.Nil(t, err)
+	assert.Equal(t, int64(12), cfg.Seed)
+	assert.Equal(t, "random", cfg.Strategy)
+	assert.Equal(t, 60, cfg.MaxRounds)
+
+	setFlag(t, "seed", "13")
+	setFlag(t, "quiet", "true")
+	cfg, err = loadConfig()
+	require.Nil(t, err)
+	assert.Equal(t, int64(13), cfg.Seed)
+	assert.Equal(t, "random", cfg.Strategy)
+	assert.Equal(t, 3, cfg.Aliens)
+	assert.Equal(t, 60, cfg.MaxRounds)
+	assert.Equal(t, "quiet", cfg.Output)
+
+	useConfigFile(t, "missing.yaml")
+	_, err = loadConfig()
+	assert.Error(t, err)
+}
+
+func TestWriteConfig(t *testing.T) {
+	cfg := Config{File: "map.txt", Directions: "8", Aliens: 4, Seed: 9, Strategy: "first", FightThreshold: 3, MaxRounds: 100, Output: "json"}
+	var buf bytes.Buffer
+	require.Nil(t, WriteConfig(&buf, cfg))
+	assert.Contains(t, buf.String(), "file: map.txt\ndirections: \"8\"\n\"N\": 4\nseed: 9\n")
+
+	// the output of config show is a config file that loads the same config
+	useConfigFile(t, writeFile(t, t.TempDir(), "aliens.yaml", buf.String()))
+	loaded, err := loadConfig()
+	require.Nil(t, err)
+	assert.Equal(t, cfg, loaded)
+}
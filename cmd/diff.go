@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fedekunze/alien_task/cosmos"
+	"github.com/spf13/cobra"
+)
+
+var diffJSON bool
+
+// diffCmd compares two maps
+var diffCmd = &cobra.Command{
+	Use:   "diff <before> <after>",
+	Short: "Compare the cities and roads of two maps",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := DiffMaps(os.Stdout, args[0], args[1], diffJSON)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(diffCmd)
+	diffCmd.Flags().BoolVar(&diffJSON, "json", false, "Print the differences as a JSON object")
+}
+
+// DiffMaps reads two map files and writes their differences to w
+func DiffMaps(w io.Writer, before string, after string, asJSON bool) error {
+	a, err := createMap(config.Directions)
+	if err != nil {
+		return err
+	}
+	err = ReadMap(before, a)
+	if err != nil {
+		return err
+	}
+	b, err := createMap(config.Directions)
+	if err != nil {
+		return err
+	}
+	err = ReadMap(after, b)
+	if err != nil {
+		return err
+	}
+	return WriteDiff(w, cosmos.Diff(a, b), asJSON)
+}
+
+// WriteDiff writes the differences between two maps, one per line, or as a
+// JSON object. Lines start with + for what was added, - for what was removed,
+// x for a destroyed city and ~ for a road that changed direction, as in
+// "~ road Baz east=Foo -> west=Foo". Roads are written like in the map, so
+// one-way roads as east->Foo.
+func WriteDiff(w io.Writer, d cosmos.MapDiff, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	}
+	if d.Empty() {
+		fmt.Fprintln(w, "The maps have the same cities and roads")
+		return nil
+	}
+	for _, name := range d.CitiesAdded {
+		fmt.Fprintln(w, "+ city "+name)
+	}
+	for _, name := range d.CitiesRemoved {
+		fmt.Fprintln(w, "- city "+name)
+	}
+	for _, name := range d.CitiesDestroyed {
+		fmt.Fprintln(w, "x city "+name)
+	}
+	for _, road := range d.RoadsAdded {
+		fmt.Fprintf(w, "+ road %v %v\n", road.From, diffRoad(road, road.Direction))
+	}
+	for _, road := range d.RoadsRemoved {
+		fmt.Fprintf(w, "- road %v %v\n", road.From, diffRoad(road, road.Direction))
+	}
+	for _, road := range d.RoadsChanged {
+		fmt.Fprintf(w, "~ road %v %v -> %v\n", road.From, diffRoad(road, road.Direction), diffRoad(road, road.NewDirection))
+	}
+	return nil
+}
+
+// diffRoad writes a road of a diff in a direction like in the map
+func diffRoad(road cosmos.RoadChange, dir cosmos.Direction) string {
+	if road.OneWay {
+		return fmt.Sprintf("%v->%v", dir, road.To)
+	}
+	return fmt.Sprintf("%v=%v", dir, road.To)
+}
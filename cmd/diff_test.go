@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/fedekunze/alien_task/cosmos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffMaps(t *testing.T) {
+	dir := t.TempDir()
+	before := writeFile(t, dir, "before.txt", "Foo north=Bar west=Baz south=Qu-ux\nBar west=Bee\n")
+	after := writeFile(t, dir, "after.txt", "Foo north=Bar east=Baz\nBar west=Bee north=Qux\n")
+
+	var out bytes.Buffer
+	require.Nil(t, DiffMaps(&out, before, after, false))
+	assert.Equal(t, "+ city Qux\n- city Qu-ux\n+ road Bar north=Qux\n~ road Baz east=Foo -> west=Foo\n", out.String())
+
+	out.Reset()
+	require.Nil(t, DiffMaps(&out, before, after, true))
+	var d cosmos.MapDiff
+	require.Nil(t, json.Unmarshal(out.Bytes(), &d))
+	assert.Equal(t, []string{"Qu-ux"}, d.CitiesRemoved)
+	assert.Empty(t, d.RoadsRemoved)
+
+	out.Reset()
+	require.Nil(t, DiffMaps(&out, before, before, false))
+	assert.Equal(t, "The maps have the same cities and roads\n", out.String())
+	assert.Error(t, DiffMaps(&out, before, "missing.txt", false))
+
+	// one-way roads are written like in the map
+	river := writeFile(t, dir, "river.txt", "Foo north=Bar west=Baz south->Qu-ux\nBar west=Bee\nQu-ux east->Bee\n")
+	out.Reset()
+	require.Nil(t, DiffMaps(&out, before, river, false))
+	assert.Equal(t, "+ road Foo south->Qu-ux\n+ road Qu-ux east->Bee\n- road Foo south=Qu-ux\n", out.String())
+}
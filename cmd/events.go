@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/fedekunze/alien_task/cosmos"
+)
+
+// EventLog writes the events of a simulation as JSON lines
+type EventLog struct {
+	enc     *json.Encoder
+	written *countingWriter
+	err     error // first error writing the log
+}
+
+// NewEventLog creates an event log that writes to w
+func NewEventLog(w io.Writer) *EventLog {
+	written := &countingWriter{w: w}
+	return &EventLog{
+		enc:     json.NewEncoder(written),
+		written: written,
+	}
+}
+
+// Listen writes an event of the simulation to the log
+func (l *EventLog) Listen(e cosmos.Event) {
+	if l.err != nil {
+		return
+	}
+	l.err = l.enc.Encode(e)
+}
+
+// Err returns the first error found writing the log
+func (l *EventLog) Err() error {
+	return l.err
+}
+
+// Written returns the bytes written to the log
+func (l *EventLog) Written() int64 {
+	return l.written.n
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "Update the golden files in testdata/golden")
+
+// TestGolden runs every map in testdata/maps with a fixed seed and compares
+// the output, the JSON result and the event log with the golden files
+func TestGolden(t *testing.T) {
+	maps, err := filepath.Glob(filepath.Join("testdata", "maps", "*.txt"))
+	require.Nil(t, err)
+	require.NotEmpty(t, maps)
+	for _, path := range maps {
+		name := strings.TrimSuffix(filepath.Base(path), ".txt")
+		t.Run(name, func(t *testing.T) {
+			cfg := Config{
+				File:           path,
+				Aliens:         4,
+				Seed:           1,
+				Strategy:       "random",
+				FightThreshold: 2,
+				MaxRounds:      100,
+			}
+			outputs := map[OutputMode]string{
+				FullOutput: ".stdout",
+				JSONOutput: ".json",
+			}
+			for mode, ext := range outputs {
+				cfg.Output = string(mode)
+				cfg.Events = filepath.Join(t.TempDir(), "events.jsonl")
+				var buf bytes.Buffer
+				require.Nil(t, RunMap(&buf, cfg))
+				checkGolden(t, name+ext, buf.Bytes())
+				events, err := os.ReadFile(cfg.Events)
+				require.Nil(t, err)
+				checkGolden(t, name+".events.jsonl", events)
+			}
+		})
+	}
+}
+
+// checkGolden compares the output with a golden file, or rewrites the golden
+// file when the tests run with -update
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", "golden", name)
+	if *update {
+		require.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.Nil(t, os.WriteFile(path, got, 0644))
+		return
+	}
+	want, err := os.ReadFile(path)
+	require.Nil(t, err, "run go test ./cmd -update to create the golden files")
+	assert.Equal(t, string(want), string(got), "output differs from %v", path)
+}
@@ -0,0 +1,300 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/fedekunze/alien_task/cosmos"
+	"github.com/fedekunze/alien_task/rpc"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var grpcAddr string
+var metricsAddr string
+
+// grpcCmd serves the simulator as a gRPC service
+var grpcCmd = &cobra.Command{
+	Use:   "grpc",
+	Short: "Run simulations as a gRPC service",
+	Run: func(cmd *cobra.Command, args []string) {
+		lis, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		if metricsAddr != "" {
+			go func() {
+				err := http.ListenAndServe(metricsAddr, MetricsHandler(cosmos.DefaultMetrics))
+				if err != nil {
+					logger.Error(err.Error())
+					os.Exit(1)
+				}
+			}()
+		}
+		srv := grpc.NewServer()
+		simulator := NewSimulatorServer()
+		simulator.SetHistory(config.History)
+		rpc.RegisterSimulatorServer(srv, simulator)
+		logger.Info("Listening...", "addr", lis.Addr().String())
+		err = srv.Serve(lis)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(grpcCmd)
+	grpcCmd.Flags().StringVar(&grpcAddr, "addr", ":9090", "Address to listen on")
+	grpcCmd.Flags().StringVar(&metricsAddr, "metrics-addr", "", "Address to serve /metrics on, disabled if empty")
+}
+
+// SimulatorServer implements the gRPC simulator service on top of cosmos
+type SimulatorServer struct {
+	rpc.UnimplementedSimulatorServer
+
+	mu      sync.Mutex
+	maps    map[string]loadedMap // by id
+	history string               // history where every battle is recorded, if any
+}
+
+// loadedMap is a map loaded into the simulator service
+type loadedMap struct {
+	text       string // in the .txt format
+	directions string // name of the direction set of its roads
+}
+
+// NewSimulatorServer creates a simulator service without any loaded map
+func NewSimulatorServer() *SimulatorServer {
+	return &SimulatorServer{
+		maps: make(map[string]loadedMap),
+	}
+}
+
+// SetHistory sets the history where every battle of the service is recorded,
+// none if empty
+func (s *SimulatorServer) SetHistory(history string) {
+	s.history = history
+}
+
+// MaxLoadedMaps is the most maps the service keeps loaded at a time
+const MaxLoadedMaps = 100
+
+// LoadMap validates the map and stores it under an id derived from its
+// contents and its directions. Loading a map that is already loaded returns
+// the same id.
+func (s *SimulatorServer) LoadMap(ctx context.Context, req *rpc.LoadMapRequest) (*rpc.LoadMapResponse, error) {
+	m, err := createMap(req.Directions)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	err = ParseMap(strings.NewReader(req.Map), m)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if m.CitiesLen() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Map has no cities")
+	}
+	loaded := loadedMap{text: req.Map, directions: m.Directions().Name()}
+	hash := sha256.Sum256([]byte(loaded.directions + "\n" + loaded.text))
+	id := hex.EncodeToString(hash[:8])
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.maps[id]; !ok && len(s.maps) >= MaxLoadedMaps {
+		return nil, status.Errorf(codes.ResourceExhausted, "At most %v maps can be loaded, unload one first", MaxLoadedMaps)
+	}
+	s.maps[id] = loaded
+	return &rpc.LoadMapResponse{MapId: id, Cities: int32(m.CitiesLen())}, nil
+}
+
+// UnloadMap removes a loaded map. The battles already running over it go on.
+func (s *SimulatorServer) UnloadMap(ctx context.Context, req *rpc.UnloadMapRequest) (*rpc.UnloadMapResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.maps[req.MapId]; !ok {
+		return nil, status.Errorf(codes.NotFound, "Couldn't find map %v", req.MapId)
+	}
+	delete(s.maps, req.MapId)
+	return &rpc.UnloadMapResponse{}, nil
+}
+
+// battle creates a new simulation over a fresh copy of a loaded map
+func (s *SimulatorServer) battle(mapID string, aliens int32) (*cosmos.Simulation, *cosmos.Map, error) {
+	if aliens < 0 {
+		return nil, nil, status.Error(codes.InvalidArgument, "Number of aliens can't be negative")
+	}
+	s.mu.Lock()
+	loaded, ok := s.maps[mapID]
+	s.mu.Unlock()
+	if !ok {
+		return nil, nil, status.Errorf(codes.NotFound, "Couldn't find map %v", mapID)
+	}
+	m, err := createMap(loaded.directions)
+	if err != nil {
+		return nil, nil, status.Error(codes.Internal, err.Error())
+	}
+	err = ParseMap(strings.NewReader(loaded.text), m)
+	if err != nil {
+		return nil, nil, status.Error(codes.Internal, err.Error())
+	}
+	sim, err := NewBattle(m, int(aliens), cosmos.DefaultConfig())
+	if err != nil {
+		return nil, nil, status.Error(codes.Internal, err.Error())
+	}
+	return sim, m, nil
+}
+
+// run runs a battle until the context is done and records it in the history
+func (s *SimulatorServer) run(ctx context.Context, sim *cosmos.Simulation, m *cosmos.Map) (int, int, error) {
+	var recorder *runRecorder
+	if s.history != "" {
+		recorder = newRunRecorder(m, sim, rulesRecord(len(m.Aliens), sim.Seed(), cosmos.DefaultConfig()))
+	}
+	aliensLeft, round, err := sim.RunContext(ctx)
+	recordRun(s.history, recorder, sim, err)
+	return aliensLeft, round, err
+}
+
+// result runs the battle until the context is done and builds its response
+func (s *SimulatorServer) result(ctx context.Context, sim *cosmos.Simulation, m *cosmos.Map) (*rpc.SimulateResponse, error) {
+	aliensLeft, round, err := s.run(ctx, sim, m)
+	if ctx.Err() != nil {
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	var buf bytes.Buffer
+	WriteMap(&buf, m)
+	return &rpc.SimulateResponse{
+		AliensLeft: int32(aliensLeft),
+		Rounds:     int32(round),
+		Map:        buf.String(),
+	}, nil
+}
+
+// Simulate runs a single battle over a loaded map
+func (s *SimulatorServer) Simulate(ctx context.Context, req *rpc.SimulateRequest) (*rpc.SimulateResponse, error) {
+	sim, m, err := s.battle(req.MapId, req.Aliens)
+	if err != nil {
+		return nil, err
+	}
+	return s.result(ctx, sim, m)
+}
+
+// WatchSimulation runs a single battle and streams its events. The battle
+// advances at the pace the client reads the events, and stops when the client
+// goes away or an event can't be sent.
+func (s *SimulatorServer) WatchSimulation(req *rpc.SimulateRequest, stream rpc.Simulator_WatchSimulationServer) error {
+	sim, m, err := s.battle(req.MapId, req.Aliens)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	var sendErr error
+	sim.Subscribe(func(e cosmos.Event) {
+		if sendErr != nil || ctx.Err() != nil {
+			return
+		}
+		sendErr = stream.Send(toProtoEvent(e))
+		if sendErr != nil {
+			cancel()
+		}
+	})
+	_, _, err = s.run(ctx, sim, m)
+	switch {
+	case sendErr != nil:
+		return sendErr
+	case ctx.Err() != nil:
+		return status.FromContextError(ctx.Err()).Err()
+	case err != nil:
+		return status.Error(codes.Internal, err.Error())
+	}
+	return nil
+}
+
+// MaxBatchRuns is the most battles a single BatchSimulate request can run
+const MaxBatchRuns = 1000
+
+// BatchSimulate runs several independent battles over the same map, as many
+// at a time as there are CPUs, each over its own copy of the map. The first
+// battle that fails, or the end of the request, stops the others.
+func (s *SimulatorServer) BatchSimulate(ctx context.Context, req *rpc.BatchSimulateRequest) (*rpc.BatchSimulateResponse, error) {
+	if req.Runs <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "Number of runs must be positive")
+	}
+	if req.Runs > MaxBatchRuns {
+		return nil, status.Errorf(codes.InvalidArgument, "At most %v runs can be batched, got %v", MaxBatchRuns, req.Runs)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make([]*rpc.SimulateResponse, req.Runs)
+	var failed sync.Once
+	var firstErr error
+	runs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(len(results), runtime.GOMAXPROCS(0)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range runs {
+				sim, m, err := s.battle(req.MapId, req.Aliens)
+				if err == nil {
+					results[i], err = s.result(ctx, sim, m)
+				}
+				if err != nil {
+					failed.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+feed:
+	for i := range results {
+		select {
+		case runs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(runs)
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if ctx.Err() != nil {
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	return &rpc.BatchSimulateResponse{Results: results}, nil
+}
+
+// toProtoEvent converts a simulation event to its protobuf message
+func toProtoEvent(e cosmos.Event) *rpc.Event {
+	aliens := make([]int32, len(e.Aliens))
+	for i, id := range e.Aliens {
+		aliens[i] = int32(id)
+	}
+	return &rpc.Event{
+		Type:      string(e.Type),
+		Round:     int32(e.Round),
+		Aliens:    aliens,
+		City:      e.City,
+		From:      e.From,
+		Direction: string(e.Direction),
+	}
+}
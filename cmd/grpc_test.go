@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/fedekunze/alien_task/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+var testMap = "Foo north=Bar west=Baz south=Qu-ux\nBar south=Foo west=Bee\n"
+
+// newTestClient starts an in-process simulator service over a bufconn listener
+func newTestClient(t *testing.T) rpc.SimulatorClient {
+	return newTestClientFor(t, NewSimulatorServer())
+}
+
+// newTestClientFor serves the given simulator service over a bufconn listener
+func newTestClientFor(t *testing.T, simulator *SimulatorServer) rpc.SimulatorClient {
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer()
+	rpc.RegisterSimulatorServer(srv, simulator)
+	go srv.Serve(lis)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.Nil(t, err)
+	t.Cleanup(func() {
+		conn.Close()
+		srv.Stop()
+	})
+	return rpc.NewSimulatorClient(conn)
+}
+
+func TestGRPCLoadMap(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	res, err := client.LoadMap(ctx, &rpc.LoadMapRequest{Map: testMap})
+	require.Nil(t, err)
+	assert.NotEmpty(t, res.MapId)
+	assert.Equal(t, int32(5), res.Cities)
+	_, err = client.LoadMap(ctx, &rpc.LoadMapRequest{Map: "Foo up=Bar"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.LoadMap(ctx, &rpc.LoadMapRequest{Map: testMap, Directions: "octagonal"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// maps in layers, with the same map in 3d under another id
+	layers, err := client.LoadMap(ctx, &rpc.LoadMapRequest{Map: "Foo up=Bar\n", Directions: "3d"})
+	require.Nil(t, err)
+	assert.Equal(t, int32(2), layers.Cities)
+	same, err := client.LoadMap(ctx, &rpc.LoadMapRequest{Map: testMap, Directions: "3d"})
+	require.Nil(t, err)
+	assert.NotEqual(t, res.MapId, same.MapId)
+	sim, err := client.Simulate(ctx, &rpc.SimulateRequest{MapId: layers.MapId, Aliens: 0})
+	require.Nil(t, err)
+	assert.Equal(t, "Foo up=Bar\nBar down=Foo\n", sim.Map)
+}
+
+func TestGRPCSimulate(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	loaded, err := client.LoadMap(ctx, &rpc.LoadMapRequest{Map: testMap})
+	require.Nil(t, err)
+	res, err := client.Simulate(ctx, &rpc.SimulateRequest{MapId: loaded.MapId, Aliens: 3})
+	require.Nil(t, err)
+	assert.True(t, res.AliensLeft >= 0 && res.AliensLeft <= 3)
+	assert.True(t, res.Rounds > 0)
+	_, err = client.Simulate(ctx, &rpc.SimulateRequest{MapId: "unknown", Aliens: 3})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestGRPCWatchSimulation(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	loaded, err := client.LoadMap(ctx, &rpc.LoadMapRequest{Map: "Foo east=Bar\n"})
+	require.Nil(t, err)
+	stream, err := client.WatchSimulation(ctx, &rpc.SimulateRequest{MapId: loaded.MapId, Aliens: 2})
+	require.Nil(t, err)
+	var types []string
+	for {
+		e, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.Nil(t, err)
+		types = append(types, e.Type)
+	}
+	// two aliens on a two city map always meet
+	assert.Contains(t, types, "fight")
+	assert.Equal(t, "destroy", types[len(types)-1])
+}
+
+// failingStream is a stream of events whose client goes away, or that fails
+// to send, after a number of events
+type failingStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	cancel func()
+	sent   int
+	limit  int
+	err    error // returned once the limit is reached, or nil to cancel instead
+}
+
+func (s *failingStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *failingStream) Send(e *rpc.Event) error {
+	s.sent++
+	if s.sent < s.limit {
+		return nil
+	}
+	if s.err == nil {
+		s.cancel()
+	}
+	return s.err
+}
+
+func TestGRPCWatchSimulationStops(t *testing.T) {
+	srv := NewSimulatorServer()
+	loaded, err := srv.LoadMap(context.Background(), &rpc.LoadMapRequest{Map: gridText(100)})
+	require.Nil(t, err)
+	req := &rpc.SimulateRequest{MapId: loaded.MapId, Aliens: 20}
+
+	// a failed send stops the battle
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := &failingStream{ctx: ctx, cancel: cancel, limit: 3, err: io.ErrClosedPipe}
+	assert.Equal(t, io.ErrClosedPipe, srv.WatchSimulation(req, stream))
+	assert.Equal(t, 3, stream.sent)
+
+	// and so does a client that goes away
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	stream = &failingStream{ctx: ctx, cancel: cancel, limit: 5}
+	assert.Equal(t, codes.Canceled, status.Code(srv.WatchSimulation(req, stream)))
+	assert.Equal(t, 5, stream.sent)
+}
+
+func TestGRPCBatchSimulate(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	loaded, err := client.LoadMap(ctx, &rpc.LoadMapRequest{Map: testMap})
+	require.Nil(t, err)
+	res, err := client.BatchSimulate(ctx, &rpc.BatchSimulateRequest{MapId: loaded.MapId, Aliens: 4, Runs: 5})
+	require.Nil(t, err)
+	assert.Len(t, res.Results, 5)
+	_, err = client.BatchSimulate(ctx, &rpc.BatchSimulateRequest{MapId: loaded.MapId, Aliens: 4})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.BatchSimulate(ctx, &rpc.BatchSimulateRequest{MapId: loaded.MapId, Aliens: 4, Runs: MaxBatchRuns + 1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.BatchSimulate(ctx, &rpc.BatchSimulateRequest{MapId: "unknown", Aliens: 4, Runs: 50})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// the battles stop with the request
+	srv := NewSimulatorServer()
+	loaded, err = srv.LoadMap(ctx, &rpc.LoadMapRequest{Map: testMap})
+	require.Nil(t, err)
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = srv.BatchSimulate(cancelled, &rpc.BatchSimulateRequest{MapId: loaded.MapId, Aliens: 4, Runs: 50})
+	assert.Equal(t, codes.Canceled, status.Code(err))
+}
+
+func TestGRPCHistory(t *testing.T) {
+	simulator := NewSimulatorServer()
+	history := filepath.Join(t.TempDir(), "history.db")
+	simulator.SetHistory(history)
+	client := newTestClientFor(t, simulator)
+	ctx := context.Background()
+	loaded, err := client.LoadMap(ctx, &rpc.LoadMapRequest{Map: testMap})
+	require.Nil(t, err)
+	res, err := client.Simulate(ctx, &rpc.SimulateRequest{MapId: loaded.MapId, Aliens: 3})
+	require.Nil(t, err)
+	_, err = client.BatchSimulate(ctx, &rpc.BatchSimulateRequest{MapId: loaded.MapId, Aliens: 3, Runs: 4})
+	require.Nil(t, err)
+	stream, err := client.WatchSimulation(ctx, &rpc.SimulateRequest{MapId: loaded.MapId, Aliens: 3})
+	require.Nil(t, err)
+	for err == nil {
+		_, err = stream.Recv()
+	}
+	require.Equal(t, io.EOF, err)
+
+	runs := historyRecords(t, history)
+	require.Len(t, runs, 6)
+	assert.Equal(t, int(res.Rounds), runs[0].Rounds)
+	assert.Equal(t, int(res.AliensLeft), runs[0].AliensLeft)
+	for _, rec := range runs {
+		assert.Equal(t, 3, rec.Aliens)
+		assert.Equal(t, runs[0].MapHash, rec.MapHash)
+	}
+}
+
+func TestGRPCUnloadMap(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	var ids []string
+	for i := 0; i < MaxLoadedMaps; i++ {
+		res, err := client.LoadMap(ctx, &rpc.LoadMapRequest{Map: fmt.Sprintf("Foo north=Bar%v\n", i)})
+		require.Nil(t, err)
+		ids = append(ids, res.MapId)
+	}
+	_, err := client.LoadMap(ctx, &rpc.LoadMapRequest{Map: "Foo north=Baz\n"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	// a map already loaded doesn't take more room
+	res, err := client.LoadMap(ctx, &rpc.LoadMapRequest{Map: "Foo north=Bar0\n"})
+	require.Nil(t, err)
+	assert.Equal(t, ids[0], res.MapId)
+
+	_, err = client.UnloadMap(ctx, &rpc.UnloadMapRequest{MapId: ids[0]})
+	require.Nil(t, err)
+	_, err = client.Simulate(ctx, &rpc.SimulateRequest{MapId: ids[0], Aliens: 2})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.UnloadMap(ctx, &rpc.UnloadMapRequest{MapId: ids[0]})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.LoadMap(ctx, &rpc.LoadMapRequest{Map: "Foo north=Baz\n"})
+	assert.Nil(t, err)
+}
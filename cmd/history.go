@@ -0,0 +1,548 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fedekunze/alien_task/cosmos"
+	"github.com/spf13/cobra"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	runsBucket = []byte("runs") // records of the runs by id
+	mapsBucket = []byte("maps") // names of the cities of each map by hash
+)
+
+// RunRecord is the summary of a run kept in the history
+type RunRecord struct {
+	ID             uint64    `json:"id"`
+	Time           time.Time `json:"time"`
+	Map            string    `json:"map"`      // map file of the run, empty if it wasn't read from a file
+	MapHash        string    `json:"map_hash"` // hash of the map before the battle
+	Cities         int       `json:"cities"`
+	Aliens         int       `json:"aliens"`
+	Seed           int64     `json:"seed"`
+	Strategy       string    `json:"strategy"`
+	FightThreshold int       `json:"fight_threshold"`
+	MaxRounds      int       `json:"max_rounds"`
+	Workers        int       `json:"workers"`
+	ResumedAt      int       `json:"resumed_at,omitempty"` // round of the checkpoint the run was resumed from
+	Rounds         int       `json:"rounds"`
+	AliensLeft     int       `json:"aliens_left"`
+	Destroyed      []string  `json:"destroyed"`        // in the order they were destroyed
+	Events         string    `json:"events,omitempty"` // event log of the run
+	Error          string    `json:"error,omitempty"`
+}
+
+// History is the local record of the runs of the simulator, kept in an
+// embedded database. The names of the cities of each map are kept once, so
+// the cities that survived a run are known without storing them in every
+// record.
+type History struct {
+	db *bolt.DB
+}
+
+// OpenHistory opens the history in the file, creating it if needed
+func OpenHistory(filename string) (*History, error) {
+	err := os.MkdirAll(filepath.Dir(filename), 0755)
+	if err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(filename, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't open history %v: %w", filename, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{runsBucket, mapsBucket} {
+			_, err := tx.CreateBucketIfNotExists(bucket)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &History{db: db}, nil
+}
+
+// Close closes the history
+func (h *History) Close() error {
+	return h.db.Close()
+}
+
+// Record adds a run to the history, with the names of the cities of its map,
+// and sets its id
+func (h *History) Record(rec *RunRecord, cities []string) error {
+	return h.db.Update(func(tx *bolt.Tx) error {
+		maps := tx.Bucket(mapsBucket)
+		if maps.Get([]byte(rec.MapHash)) == nil {
+			names, err := json.Marshal(cities)
+			if err != nil {
+				return err
+			}
+			err = maps.Put([]byte(rec.MapHash), names)
+			if err != nil {
+				return err
+			}
+		}
+		runs := tx.Bucket(runsBucket)
+		id, err := runs.NextSequence()
+		if err != nil {
+			return err
+		}
+		rec.ID = id
+		value, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		return runs.Put(runKey(id), value)
+	})
+}
+
+// Runs returns every run of the history, in the order they were recorded
+func (h *History) Runs() ([]RunRecord, error) {
+	var records []RunRecord
+	err := h.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(runsBucket).ForEach(func(_, value []byte) error {
+			var rec RunRecord
+			err := json.Unmarshal(value, &rec)
+			records = append(records, rec)
+			return err
+		})
+	})
+	return records, err
+}
+
+// Run returns the run of the history with the given id
+func (h *History) Run(id uint64) (RunRecord, error) {
+	var rec RunRecord
+	err := h.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(runsBucket).Get(runKey(id))
+		if value == nil {
+			return fmt.Errorf("Couldn't find run %v", id)
+		}
+		return json.Unmarshal(value, &rec)
+	})
+	return rec, err
+}
+
+// Survivors returns the cities of the map of a run that were not destroyed
+func (h *History) Survivors(rec RunRecord) ([]string, error) {
+	cities, err := h.cities(rec.MapHash)
+	if err != nil {
+		return nil, err
+	}
+	destroyed := nameSet(rec.Destroyed)
+	survivors := cities[:0]
+	for _, name := range cities {
+		if !destroyed[name] {
+			survivors = append(survivors, name)
+		}
+	}
+	return survivors, nil
+}
+
+// HashMap returns the hash of the cities and available roads of a map, which
+// doesn't depend on how its file was written: the cities are hashed sorted by
+// name, each with its roads in the order of the directions
+func HashMap(m *cosmos.Map) string {
+	var buf bytes.Buffer
+	m.WriteTo(&buf)
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	slices.Sort(lines)
+	hash := sha256.New()
+	for _, line := range lines {
+		if line != "" {
+			fmt.Fprintln(hash, line)
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)[:16])
+}
+
+// ----- History commands -----
+
+// historyCmd groups the commands that query the history of the runs
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Query the history of the simulations that were run",
+}
+
+var historyMap string
+var historySurvived string
+var historyJSON bool
+
+// historyListCmd lists the runs of the history
+var historyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the runs, optionally only the ones of a map or where a city survived",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		withHistory(func(h *History) error {
+			return ListRuns(os.Stdout, h, historyMap, historySurvived)
+		})
+	},
+}
+
+// historyShowCmd prints the details of a run
+var historyShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Print the parameters and the result of a run",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		withHistory(func(h *History) error {
+			id, err := parseRunID(args[0])
+			if err != nil {
+				return err
+			}
+			return ShowRun(os.Stdout, h, id, historyJSON)
+		})
+	},
+}
+
+// historyCompareCmd compares two runs
+var historyCompareCmd = &cobra.Command{
+	Use:   "compare <id> <id>",
+	Short: "Compare the parameters and the results of two runs",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		withHistory(func(h *History) error {
+			a, err := parseRunID(args[0])
+			if err != nil {
+				return err
+			}
+			b, err := parseRunID(args[1])
+			if err != nil {
+				return err
+			}
+			return CompareRuns(os.Stdout, h, a, b)
+		})
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(historyCmd)
+	historyCmd.AddCommand(historyListCmd, historyShowCmd, historyCompareCmd)
+	historyListCmd.Flags().StringVar(&historyMap, "map", "", "Only the runs of this map file or map hash prefix")
+	historyListCmd.Flags().StringVar(&historySurvived, "survived", "", "Only the runs where this city survived")
+	historyShowCmd.Flags().BoolVar(&historyJSON, "json", false, "Print the run as a JSON object")
+}
+
+// withHistory opens the history of the configuration for a command
+func withHistory(fn func(h *History) error) {
+	err := fmt.Errorf("No history file given, set it with --history")
+	if config.History != "" {
+		var h *History
+		h, err = OpenHistory(config.History)
+		if err == nil {
+			err = fn(h)
+			h.Close()
+		}
+	}
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+// ListRuns writes a table with the runs of the history of the given map, or
+// of every map if empty, where the given city survived, if any
+func ListRuns(w io.Writer, h *History, mapFilter string, survived string) error {
+	runs, err := h.Runs()
+	if err != nil {
+		return err
+	}
+	// whether each map has the city, as many runs share the same map
+	hasCity := make(map[string]bool)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTIME\tMAP\tHASH\tALIENS\tSEED\tROUNDS\tLEFT\tDESTROYED")
+	for _, rec := range runs {
+		if mapFilter != "" && rec.Map != absPath(mapFilter) && !strings.HasPrefix(rec.MapHash, mapFilter) {
+			continue
+		}
+		if survived != "" {
+			has, ok := hasCity[rec.MapHash]
+			if !ok {
+				cities, err := h.cities(rec.MapHash)
+				if err != nil {
+					return err
+				}
+				has = slices.Contains(cities, survived)
+				hasCity[rec.MapHash] = has
+			}
+			if !has || slices.Contains(rec.Destroyed, survived) {
+				continue
+			}
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n", rec.ID, rec.Time.Format(time.DateTime), rec.Map,
+			rec.MapHash[:min(12, len(rec.MapHash))], rec.Aliens, rec.Seed, rec.Rounds, rec.AliensLeft, len(rec.Destroyed))
+	}
+	return tw.Flush()
+}
+
+// ShowRun writes the parameters and the result of a run, as key: value lines
+// or as a JSON object
+func ShowRun(w io.Writer, h *History, id uint64, asJSON bool) error {
+	rec, err := h.Run(id)
+	if err != nil {
+		return err
+	}
+	survivors, err := h.Survivors(rec)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			RunRecord
+			Survivors []string `json:"survivors"`
+		}{rec, survivors})
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 1, ' ', 0)
+	for _, line := range runLines(rec) {
+		fmt.Fprintf(tw, "%v:\t%v\n", line[0], line[1])
+	}
+	fmt.Fprintf(tw, "destroyed:\t%v\n", strings.Join(rec.Destroyed, ", "))
+	fmt.Fprintf(tw, "survivors:\t%v\n", strings.Join(survivors, ", "))
+	return tw.Flush()
+}
+
+// CompareRuns writes the parameters and the results of two runs side by side,
+// followed by the cities destroyed in only one of them
+func CompareRuns(w io.Writer, h *History, a uint64, b uint64) error {
+	runA, err := h.Run(a)
+	if err != nil {
+		return err
+	}
+	runB, err := h.Run(b)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "\trun %v\trun %v\t\n", a, b)
+	linesB := runLines(runB)
+	for i, line := range runLines(runA) {
+		mark := ""
+		if line[1] != linesB[i][1] {
+			mark = "*"
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\n", line[0], line[1], linesB[i][1], mark)
+	}
+	tw.Flush()
+	if runA.MapHash != runB.MapHash {
+		fmt.Fprintln(w, "The runs are on different maps")
+	}
+	fmt.Fprintf(w, "Destroyed in both: %v\n", strings.Join(intersect(runA.Destroyed, runB.Destroyed), ", "))
+	fmt.Fprintf(w, "Destroyed only in run %v: %v\n", a, strings.Join(subtract(runA.Destroyed, runB.Destroyed), ", "))
+	fmt.Fprintf(w, "Destroyed only in run %v: %v\n", b, strings.Join(subtract(runB.Destroyed, runA.Destroyed), ", "))
+	return nil
+}
+
+// ----- Unexported functions -----
+
+// defaultHistory returns the history where the runs are recorded unless
+// --history is given, or none if there is no home directory
+func defaultHistory() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".aliens", "history.db")
+}
+
+// runRecorder keeps the record of a battle while it runs
+type runRecorder struct {
+	rec    RunRecord
+	cities []string // names of the cities of the map, in the order of their ids
+}
+
+// newRunRecorder starts the record of a battle before it runs, with the hash
+// and the cities of its map, and adds the cities destroyed as it runs. The
+// record holds the parameters of the battle, and the cities destroyed before
+// a resumed battle.
+func newRunRecorder(m *cosmos.Map, sim *cosmos.Simulation, rec RunRecord) *runRecorder {
+	r := &runRecorder{rec: rec}
+	if r.rec.MapHash == "" {
+		r.rec.MapHash = HashMap(m)
+	}
+	r.rec.Cities = m.CitiesLen()
+	_, r.rec.ResumedAt = sim.Progress()
+	r.cities = make([]string, m.CitiesLen())
+	for i := range r.cities {
+		r.cities[i] = m.CityName(i)
+	}
+	sim.Subscribe(func(e cosmos.Event) {
+		if e.Type == cosmos.DestroyEvent {
+			r.rec.Destroyed = append(r.rec.Destroyed, e.City)
+		}
+	})
+	return r
+}
+
+// rulesRecord returns the record of a battle of the given aliens and rules
+func rulesRecord(aliens int, seed int64, rules cosmos.Config) RunRecord {
+	return RunRecord{
+		Aliens:         aliens,
+		Seed:           seed,
+		Strategy:       string(rules.Strategy),
+		FightThreshold: rules.FightThreshold,
+		MaxRounds:      rules.MaxRounds,
+		Workers:        rules.Workers,
+	}
+}
+
+// configRecord returns the record of a battle run with the configuration
+func configRecord(cfg Config) RunRecord {
+	rec := rulesRecord(cfg.Aliens, cfg.Seed, cfg.Rules())
+	rec.Map = absPath(cfg.File)
+	if cfg.Events != "" {
+		rec.Events = absPath(cfg.Events)
+	}
+	return rec
+}
+
+// historyMu serializes the runs recorded by this process, as every record
+// locks the whole history file
+var historyMu sync.Mutex
+
+// recordRun adds a finished battle to the history file, if any. Failing to
+// record it is only logged, as the battle itself went fine.
+func recordRun(history string, r *runRecorder, sim *cosmos.Simulation, runErr error) {
+	if history == "" || r == nil {
+		return
+	}
+	rec := r.rec
+	rec.Time = time.Now()
+	rec.AliensLeft, rec.Rounds = sim.Progress()
+	if runErr != nil {
+		rec.Error = runErr.Error()
+	}
+	historyMu.Lock()
+	h, err := OpenHistory(history)
+	if err == nil {
+		err = h.Record(&rec, r.cities)
+		h.Close()
+	}
+	historyMu.Unlock()
+	if err != nil {
+		logger.Warn("Couldn't record the run in the history", "history", history, "error", err)
+		return
+	}
+	logger.Debug("Recorded run", "id", rec.ID, "history", history)
+}
+
+// cities returns the names of the cities of a map of the history
+func (h *History) cities(hash string) ([]string, error) {
+	var cities []string
+	err := h.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(mapsBucket).Get([]byte(hash))
+		if value == nil {
+			return fmt.Errorf("Couldn't find map %v", hash)
+		}
+		return json.Unmarshal(value, &cities)
+	})
+	return cities, err
+}
+
+// runLines returns the parameters and the results of a run as key and value
+func runLines(rec RunRecord) [][2]string {
+	lines := [][2]string{
+		{"id", strconv.FormatUint(rec.ID, 10)},
+		{"time", rec.Time.Format(time.DateTime)},
+		{"map", rec.Map},
+		{"map hash", rec.MapHash},
+		{"cities", strconv.Itoa(rec.Cities)},
+		{"aliens", strconv.Itoa(rec.Aliens)},
+		{"seed", strconv.FormatInt(rec.Seed, 10)},
+		{"strategy", rec.Strategy},
+		{"fight threshold", strconv.Itoa(rec.FightThreshold)},
+		{"max rounds", strconv.Itoa(rec.MaxRounds)},
+		{"workers", strconv.Itoa(rec.Workers)},
+		{"resumed at", strconv.Itoa(rec.ResumedAt)},
+		{"rounds", strconv.Itoa(rec.Rounds)},
+		{"aliens left", strconv.Itoa(rec.AliensLeft)},
+		{"cities destroyed", strconv.Itoa(len(rec.Destroyed))},
+		{"events", rec.Events},
+	}
+	if rec.Error != "" {
+		lines = append(lines, [2]string{"error", rec.Error})
+	}
+	return lines
+}
+
+// runKey is the key of a run in the database, which keeps the runs in order
+func runKey(id uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, id)
+}
+
+// parseRunID parses the id of a run given in the command line
+func parseRunID(arg string) (uint64, error) {
+	id, err := strconv.ParseUint(arg, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid run id %v", arg)
+	}
+	return id, nil
+}
+
+// absPath returns the absolute path of a file, or the name as it is if it
+// has none, like - for stdin
+func absPath(filename string) string {
+	if filename == "-" {
+		return filename
+	}
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return filename
+	}
+	return abs
+}
+
+// intersect returns the names in both lists, in the order of the first one
+func intersect(a []string, b []string) []string {
+	in := nameSet(b)
+	var both []string
+	for _, name := range a {
+		if in[name] {
+			both = append(both, name)
+		}
+	}
+	return both
+}
+
+// subtract returns the names of the first list missing from the second one
+func subtract(a []string, b []string) []string {
+	in := nameSet(b)
+	var only []string
+	for _, name := range a {
+		if !in[name] {
+			only = append(only, name)
+		}
+	}
+	return only
+}
+
+// nameSet returns the set of the names of a list
+func nameSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
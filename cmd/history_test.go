@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fedekunze/alien_task/cosmos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// historyRuns runs the test map with each seed, recording the runs in a
+// history in dir
+func historyRuns(t *testing.T, dir string, seeds ...int64) string {
+	cfg := Config{
+		File:           writeFile(t, dir, "map.txt", testMap),
+		Aliens:         3,
+		Strategy:       "random",
+		FightThreshold: 2,
+		MaxRounds:      100,
+		Output:         string(SummaryOutput),
+		History:        filepath.Join(dir, "history", "history.db"),
+	}
+	for _, seed := range seeds {
+		cfg.Seed = seed
+		require.Nil(t, RunMap(io.Discard, cfg))
+	}
+	return cfg.History
+}
+
+// historyRecords returns the runs recorded in a history
+func historyRecords(t *testing.T, filename string) []RunRecord {
+	h, err := OpenHistory(filename)
+	require.Nil(t, err)
+	defer h.Close()
+	runs, err := h.Runs()
+	require.Nil(t, err)
+	return runs
+}
+
+func TestHistoryRecord(t *testing.T) {
+	dir := t.TempDir()
+	h, err := OpenHistory(historyRuns(t, dir, 1, 2, 3))
+	require.Nil(t, err)
+	defer h.Close()
+
+	runs, err := h.Runs()
+	require.Nil(t, err)
+	require.Len(t, runs, 3)
+	for i, rec := range runs {
+		assert.Equal(t, uint64(i+1), rec.ID)
+		assert.Equal(t, int64(i+1), rec.Seed)
+		assert.Equal(t, filepath.Join(dir, "map.txt"), rec.Map)
+		assert.Equal(t, runs[0].MapHash, rec.MapHash)
+		assert.Equal(t, 5, rec.Cities)
+		assert.Equal(t, 3, rec.Aliens)
+		survivors, err := h.Survivors(rec)
+		require.Nil(t, err)
+		assert.Len(t, survivors, 5-len(rec.Destroyed))
+	}
+	_, err = h.Run(4)
+	assert.EqualError(t, err, "Couldn't find run 4")
+}
+
+func TestHashMap(t *testing.T) {
+	hashes := make(map[string]bool)
+	for _, text := range []string{
+		"Foo north=Bar west=Baz\nBar west=Bee\nQux east->Foo\n",
+		"Qux east->Foo\nBar west=Bee south=Foo\nBaz east=Foo\n",
+		"Bee east=Bar\nBaz east=Foo\nQux east->Foo\nFoo north=Bar\n",
+	} {
+		m := cosmos.CreateMap()
+		require.Nil(t, ParseMap(strings.NewReader(text), m))
+		hashes[HashMap(m)] = true
+	}
+	assert.Len(t, hashes, 1)
+
+	m := cosmos.CreateMap()
+	require.Nil(t, ParseMap(strings.NewReader("Foo north=Bar west=Baz\nBar west=Bee\nQux east->Bar\n"), m))
+	assert.False(t, hashes[HashMap(m)])
+}
+
+func TestHistoryCommands(t *testing.T) {
+	dir := t.TempDir()
+	h, err := OpenHistory(historyRuns(t, dir, 1, 2))
+	require.Nil(t, err)
+	defer h.Close()
+	runs, err := h.Runs()
+	require.Nil(t, err)
+
+	var out bytes.Buffer
+	require.Nil(t, ListRuns(&out, h, "", ""))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "ID"))
+	out.Reset()
+	require.Nil(t, ListRuns(&out, h, runs[0].MapHash[:6], ""))
+	assert.Len(t, strings.Split(strings.TrimSpace(out.String()), "\n"), 3)
+	out.Reset()
+	require.Nil(t, ListRuns(&out, h, "other.txt", ""))
+	assert.Len(t, strings.Split(strings.TrimSpace(out.String()), "\n"), 1)
+
+	// the runs where a city survived
+	survivors, err := h.Survivors(runs[0])
+	require.Nil(t, err)
+	require.NotEmpty(t, survivors)
+	out.Reset()
+	require.Nil(t, ListRuns(&out, h, "", survivors[0]))
+	assert.Contains(t, out.String(), "\n1 ")
+	require.NotEmpty(t, runs[0].Destroyed)
+	out.Reset()
+	require.Nil(t, ListRuns(&out, h, "", runs[0].Destroyed[0]))
+	assert.NotContains(t, out.String(), "\n1 ")
+	out.Reset()
+	require.Nil(t, ListRuns(&out, h, "", "Nowhere"))
+	assert.Len(t, strings.Split(strings.TrimSpace(out.String()), "\n"), 1)
+
+	out.Reset()
+	require.Nil(t, ShowRun(&out, h, 1, false))
+	assert.Contains(t, out.String(), "seed:             1\n")
+	assert.Contains(t, out.String(), "survivors:        "+strings.Join(survivors, ", ")+"\n")
+	out.Reset()
+	require.Nil(t, ShowRun(&out, h, 2, true))
+	var shown struct {
+		RunRecord
+		Survivors []string `json:"survivors"`
+	}
+	require.Nil(t, json.Unmarshal(out.Bytes(), &shown))
+	assert.Equal(t, runs[1].Seed, shown.Seed)
+	assert.Equal(t, 5-len(runs[1].Destroyed), len(shown.Survivors))
+
+	out.Reset()
+	require.Nil(t, CompareRuns(&out, h, 1, 2))
+	assert.Contains(t, out.String(), "run 1")
+	assert.Regexp(t, `seed +1 +2 +\*`, out.String())
+	assert.NotContains(t, out.String(), "different maps")
+	assert.Contains(t, out.String(), "Destroyed only in run 2:")
+	assert.EqualError(t, CompareRuns(&out, h, 1, 9), "Couldn't find run 9")
+}
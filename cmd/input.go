@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// progressEvery is how often the progress of the reading of a map is logged
+var progressEvery = 2 * time.Second
+
+// gzipMagic are the first bytes of gzip-compressed data
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// OpenMap opens a map in the .txt format for reading: a .txt file, a
+// gzip-compressed .txt.gz file or, if the filename is -, the standard input.
+// Compressed input is recognized by its contents, so the standard input can
+// be compressed as well. The progress of the reading is logged as the map is
+// read.
+func OpenMap(filename string) (io.ReadCloser, error) {
+	input := &mapInput{name: filename, size: -1, start: time.Now()}
+	if filename == "-" {
+		input.name = "stdin"
+		input.file = os.Stdin
+	} else {
+		if !strings.HasSuffix(strings.TrimSuffix(filename, ".gz"), ".txt") {
+			return nil, fmt.Errorf("File %v does not have .txt format", filename)
+		}
+		file, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		info, err := file.Stat()
+		if err == nil && info.Mode().IsRegular() {
+			input.size = info.Size()
+		}
+		input.file = file
+	}
+	input.last = input.start
+	buffered := bufio.NewReader(progressReader{input})
+	input.Reader = buffered
+	magic, _ := buffered.Peek(len(gzipMagic))
+	if bytes.Equal(magic, gzipMagic) {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			input.Close()
+			return nil, fmt.Errorf("Couldn't decompress %v: %v", input.name, err)
+		}
+		input.Reader = gz
+		input.gzip = gz
+	}
+	return input, nil
+}
+
+// mapInput is a map being read, decompressed if needed
+type mapInput struct {
+	io.Reader
+	name  string
+	file  *os.File
+	gzip  *gzip.Reader // nil if the map is not compressed
+	size  int64        // size of the file, -1 if unknown
+	read  int64        // bytes read from the file
+	start time.Time
+	last  time.Time // last time the progress was logged
+}
+
+// Close closes the map and logs how much of it was read
+func (input *mapInput) Close() error {
+	logger.Debug("Read map", "file", input.name, "bytes", input.read, "duration", time.Since(input.start))
+	if input.gzip != nil {
+		input.gzip.Close()
+	}
+	if input.file == os.Stdin {
+		return nil
+	}
+	return input.file.Close()
+}
+
+// progressReader reads the file of a map, logging the progress
+type progressReader struct {
+	input *mapInput
+}
+
+func (p progressReader) Read(buf []byte) (int, error) {
+	input := p.input
+	n, err := input.file.Read(buf)
+	input.read += int64(n)
+	if now := time.Now(); now.Sub(input.last) >= progressEvery {
+		input.last = now
+		args := []any{"file", input.name, "bytes", input.read}
+		if input.size > 0 {
+			args = append(args, "percent", input.read*100/input.size)
+		}
+		logger.Info("Reading map...", args...)
+	}
+	return n, err
+}
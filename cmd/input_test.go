@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fedekunze/alien_task/cosmos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const inputMap = "Foo north=Bar west=Baz south=Qu-ux\nBar south=Foo west=Bee\n"
+
+// gzipped compresses the text
+func gzipped(t *testing.T, text string) string {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte(text))
+	require.Nil(t, err)
+	require.Nil(t, gz.Close())
+	return buf.String()
+}
+
+func TestReadMap(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"map.txt":    inputMap,
+		"map.txt.gz": gzipped(t, inputMap),
+	} {
+		m := cosmos.CreateMap()
+		require.Nil(t, ReadMap(writeFile(t, dir, name, content), m), name)
+		assert.Equal(t, 5, m.CitiesLen(), name)
+	}
+
+	err := ReadMap(writeFile(t, dir, "map.csv", inputMap), cosmos.CreateMap())
+	assert.EqualError(t, err, "File "+filepath.Join(dir, "map.csv")+" does not have .txt format")
+	err = ReadMap(writeFile(t, dir, "broken.txt.gz", "\x1f\x8bnot gzip"), cosmos.CreateMap())
+	assert.ErrorContains(t, err, "Couldn't decompress")
+	err = ReadMap(writeFile(t, dir, "invalid.txt", "Foo north=Bar\nFoo north=Baz\n"), cosmos.CreateMap())
+	assert.EqualError(t, err, "Line 2: City Foo has roads north to both Bar and Baz")
+}
+
+func TestReadMapStdin(t *testing.T) {
+	stdin := os.Stdin
+	defer func() { os.Stdin = stdin }()
+	for _, content := range []string{inputMap, gzipped(t, inputMap)} {
+		file, err := os.Open(writeFile(t, t.TempDir(), "stdin", content))
+		require.Nil(t, err)
+		os.Stdin = file
+		m := cosmos.CreateMap()
+		require.Nil(t, ReadMap("-", m))
+		assert.Equal(t, 5, m.CitiesLen())
+		file.Close()
+	}
+}
+
+func TestReadMapLongLine(t *testing.T) {
+	// longer than the 64KB limit of a bufio.Scanner
+	var line strings.Builder
+	line.WriteString("Foo")
+	for i := 0; i < 20000; i++ {
+		line.WriteString(" north=" + strings.Repeat("a", 10))
+	}
+	name := strings.Repeat("b", 100000)
+	m := cosmos.CreateMap()
+	err := ReadMap(writeFile(t, t.TempDir(), "long.txt", name+" east=Foo\n"+line.String()+"\n"), m)
+	require.Nil(t, err)
+	assert.Equal(t, 3, m.CitiesLen())
+	city, err := m.GetCity(name)
+	require.Nil(t, err)
+	road, _ := city.GetRoad(cosmos.East.IntValue())
+	assert.Equal(t, "Foo", road.Destination().Name())
+}
+
+func TestReadMapProgress(t *testing.T) {
+	every, l := progressEvery, logger
+	defer func() { progressEvery, logger = every, l }()
+	progressEvery = 0
+	var logs bytes.Buffer
+	logger = slog.New(slog.NewTextHandler(&logs, nil))
+
+	require.Nil(t, ReadMap(writeFile(t, t.TempDir(), "map.txt", inputMap), cosmos.CreateMap()))
+	assert.Contains(t, logs.String(), `msg="Reading map..."`)
+	assert.Contains(t, logs.String(), "bytes=58 percent=100")
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+var logLevel string
+var logFormat string
+
+// logger is used by every command, and handed down to the simulations they
+// run. Logs go to stderr so the results on stdout stay machine-parseable.
+var logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+// NewLogger creates a logger that writes records of at least the given level
+// ("debug", "info", "warn" or "error") in the given format ("text" or "json")
+func NewLogger(w io.Writer, level string, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	err := lvl.UnmarshalText([]byte(level))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid log level %v", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(format) {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("Invalid log format %v", format)
+	}
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := NewLogger(&buf, "warn", "json")
+	require.Nil(t, err)
+	l.Info("Hidden")
+	l.Warn("Shown", "round", 3)
+	var record map[string]interface{}
+	require.Nil(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "Shown", record["msg"])
+	assert.Equal(t, "WARN", record["level"])
+	assert.Equal(t, float64(3), record["round"])
+
+	buf.Reset()
+	l, err = NewLogger(&buf, "DEBUG", "text")
+	require.Nil(t, err)
+	l.Debug("Read line", "line", "Foo north=Bar")
+	assert.Contains(t, buf.String(), "level=DEBUG msg=\"Read line\" line=\"Foo north=Bar\"")
+
+	_, err = NewLogger(&buf, "verbose", "text")
+	assert.Error(t, err)
+	_, err = NewLogger(&buf, "info", "xml")
+	assert.Error(t, err)
+}
@@ -0,0 +1,16 @@
+package cmd
+
+import (
+	"net/http"
+
+	"github.com/fedekunze/alien_task/cosmos"
+)
+
+// MetricsHandler serves the metrics of the engine in the Prometheus text
+// exposition format
+func MetricsHandler(metrics *cosmos.Metrics) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		metrics.WriteTo(w)
+	})
+}
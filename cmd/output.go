@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/fedekunze/alien_task/cosmos"
+)
+
+// OutputMode selects what is printed on stdout about a simulation
+type OutputMode string
+
+const (
+	// FullOutput prints every destroyed city and the surviving map
+	FullOutput OutputMode = "full"
+	// QuietOutput prints only the surviving map
+	QuietOutput OutputMode = "quiet"
+	// SummaryOutput prints only the final counts of the simulation
+	SummaryOutput OutputMode = "summary"
+	// JSONOutput prints the result of the simulation as a JSON object
+	JSONOutput OutputMode = "json"
+)
+
+// Report presents the results of a simulation according to an output mode
+type Report struct {
+	w         io.Writer
+	mode      OutputMode
+	destroyed []string // cities destroyed so far, in order
+}
+
+// Result is the JSON representation of the end of a simulation
+type Result struct {
+	Rounds     int      `json:"rounds"`
+	AliensLeft int      `json:"aliens_left"`
+	Destroyed  []string `json:"destroyed"` // in the order they were destroyed
+	Map        []string `json:"map"`       // surviving map in the .txt format
+}
+
+// NewReport creates a report that writes to w
+func NewReport(w io.Writer, mode OutputMode) *Report {
+	return &Report{
+		w:    w,
+		mode: mode,
+	}
+}
+
+// Listen records an event of the simulation, printing the destroyed cities
+// as they happen in full mode
+func (r *Report) Listen(e cosmos.Event) {
+	if e.Type != cosmos.DestroyEvent {
+		return
+	}
+	r.destroyed = append(r.destroyed, e.City)
+	if r.mode != FullOutput {
+		return
+	}
+	aliens := make([]string, len(e.Aliens))
+	for i, id := range e.Aliens {
+		aliens[i] = "alien " + strconv.Itoa(id)
+	}
+	last := len(aliens) - 1
+	by := aliens[last]
+	if last > 0 {
+		by = strings.Join(aliens[:last], ", ") + " and " + aliens[last]
+	}
+	fmt.Fprintln(r.w)
+	fmt.Fprintln(r.w, "––––––––––– Round "+strconv.Itoa(e.Round)+" –––––––––––")
+	fmt.Fprintln(r.w, e.City+" has been destroyed by "+by+"!")
+}
+
+// Print prints the final results of the simulation
+func (r *Report) Print(m *cosmos.Map, aliensLeft int, round int) {
+	switch r.mode {
+	case SummaryOutput:
+		fmt.Fprintln(r.w, "aliens_left="+strconv.Itoa(aliensLeft))
+		fmt.Fprintln(r.w, "cities_destroyed="+strconv.Itoa(len(r.destroyed)))
+		fmt.Fprintln(r.w, "rounds="+strconv.Itoa(round))
+	case JSONOutput:
+		var buf bytes.Buffer
+		WriteMap(&buf, m)
+		result := Result{
+			Rounds:     round,
+			AliensLeft: aliensLeft,
+			Destroyed:  append([]string{}, r.destroyed...),
+			Map:        strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"),
+		}
+		if buf.Len() == 0 {
+			result.Map = []string{}
+		}
+		enc := json.NewEncoder(r.w)
+		enc.SetIndent("", "  ")
+		enc.Encode(result)
+	case QuietOutput:
+		WriteMap(r.w, m)
+	default:
+		fmt.Fprintln(r.w)
+		fmt.Fprintln(r.w, "SIMULATION ENDED AT ROUND "+strconv.Itoa(round))
+		fmt.Fprintln(r.w, "Aliens left : "+strconv.Itoa(aliensLeft)+". Printing results:")
+		fmt.Fprintln(r.w)
+		WriteMap(r.w, m)
+		fmt.Fprintln(r.w)
+	}
+}
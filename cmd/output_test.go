@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/fedekunze/alien_task/cosmos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReport(t *testing.T) {
+	m := cosmos.CreateMap()
+	require.Nil(t, ParseMap(strings.NewReader("Foo north=Bar\n"), m))
+	destroy := cosmos.Event{Type: cosmos.DestroyEvent, Round: 4, Aliens: []int{1, 2, 7}, City: "Baz"}
+
+	var buf bytes.Buffer
+	report := NewReport(&buf, FullOutput)
+	report.Listen(cosmos.Event{Type: cosmos.MoveEvent, Aliens: []int{1}, City: "Foo"})
+	report.Listen(destroy)
+	assert.Equal(t, "\n––––––––––– Round 4 –––––––––––\nBaz has been destroyed by alien 1, alien 2 and alien 7!\n", buf.String())
+
+	buf.Reset()
+	report = NewReport(&buf, QuietOutput)
+	report.Listen(destroy)
+	report.Print(m, 1, 10)
+	assert.Equal(t, "Foo north=Bar\nBar south=Foo\n", buf.String())
+
+	buf.Reset()
+	report = NewReport(&buf, SummaryOutput)
+	report.Listen(destroy)
+	report.Print(m, 1, 10)
+	assert.Equal(t, "aliens_left=1\ncities_destroyed=1\nrounds=10\n", buf.String())
+}
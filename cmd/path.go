@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/fedekunze/alien_task/cosmos"
+	"github.com/spf13/cobra"
+)
+
+var pathJSON bool
+var pathMatrix bool
+
+// pathCmd finds the shortest path between two cities of a map, or the
+// distances between several of them
+var pathCmd = &cobra.Command{
+	Use:   "path <map> <from> <to> | path --matrix <map> [city...]",
+	Short: "Find the shortest path between two cities of a map, or the distances between several of them",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if pathMatrix {
+			return cobra.MinimumNArgs(1)(cmd, args)
+		}
+		return cobra.ExactArgs(3)(cmd, args)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		if pathMatrix {
+			err = WriteDistances(os.Stdout, args[0], args[1:], pathJSON)
+		} else {
+			err = FindPath(os.Stdout, args[0], args[1], args[2], pathJSON)
+		}
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(pathCmd)
+	pathCmd.Flags().BoolVar(&pathJSON, "json", false, "Print the path or the distances as a JSON object")
+	pathCmd.Flags().BoolVar(&pathMatrix, "matrix", false, "Print the roads of the shortest path between every pair of the given cities, or of every city")
+}
+
+// Distances is the number of roads of the shortest path from each of the
+// cities to each of them, -1 if there is none
+type Distances struct {
+	Cities    []string `json:"cities"`
+	Distances [][]int  `json:"distances"` // from the city of each row to the city of each column
+}
+
+// FindPath reads a map file and writes the shortest path between two of its
+// cities to w, one road per line, like in the .txt format:
+//
+//	2 roads from Foo to Baz
+//	Foo north=Bar
+//	Bar east->Baz
+func FindPath(w io.Writer, filename string, from string, to string, asJSON bool) error {
+	m, err := createMap(config.Directions)
+	if err != nil {
+		return err
+	}
+	err = ReadMap(filename, m)
+	if err != nil {
+		return err
+	}
+	path, err := cosmos.ShortestPath(m, from, to)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(path)
+	}
+	roads := "roads"
+	if path.Len() == 1 {
+		roads = "road"
+	}
+	fmt.Fprintf(w, "%v %v from %v to %v\n", path.Len(), roads, from, to)
+	for i, dir := range path.Directions {
+		road := "="
+		if path.OneWay[i] {
+			road = "->"
+		}
+		fmt.Fprintf(w, "%v %v%v%v\n", path.Cities[i], dir, road, path.Cities[i+1])
+	}
+	return nil
+}
+
+// WriteDistances reads a map file and writes the distance matrix of the given
+// cities, or of every city of the map if none is given, to w as a table where
+// - marks the cities with no path between them:
+//
+//	     Foo  Bar  Baz
+//	Foo  0    1    2
+//	Bar  1    0    1
+//	Baz  2    1    0
+func WriteDistances(w io.Writer, filename string, cities []string, asJSON bool) error {
+	m, err := createMap(config.Directions)
+	if err != nil {
+		return err
+	}
+	err = ReadMap(filename, m)
+	if err != nil {
+		return err
+	}
+	if len(cities) == 0 {
+		cities = make([]string, m.CitiesLen())
+		for i := range cities {
+			cities[i] = m.CityName(i)
+		}
+	}
+	matrix, err := cosmos.DistanceMatrix(m, cities)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(Distances{Cities: cities, Distances: matrix})
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "\t"+strings.Join(cities, "\t"))
+	for i, row := range matrix {
+		fmt.Fprint(tw, cities[i])
+		for _, dist := range row {
+			if dist < 0 {
+				fmt.Fprint(tw, "\t-")
+			} else {
+				fmt.Fprintf(tw, "\t%v", dist)
+			}
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/fedekunze/alien_task/cosmos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindPath(t *testing.T) {
+	dir := t.TempDir()
+	filename := writeFile(t, dir, "map.txt", "Foo north=Bar\nBar east=Baz\nQux\nBaz east->Bee\n")
+
+	var out bytes.Buffer
+	require.Nil(t, FindPath(&out, filename, "Foo", "Baz", false))
+	assert.Equal(t, "2 roads from Foo to Baz\nFoo north=Bar\nBar east=Baz\n", out.String())
+	out.Reset()
+	require.Nil(t, FindPath(&out, filename, "Bar", "Bee", false))
+	assert.Equal(t, "2 roads from Bar to Bee\nBar east=Baz\nBaz east->Bee\n", out.String())
+
+	out.Reset()
+	require.Nil(t, FindPath(&out, filename, "Baz", "Bar", true))
+	var path cosmos.Path
+	require.Nil(t, json.Unmarshal(out.Bytes(), &path))
+	assert.Equal(t, []string{"Baz", "Bar"}, path.Cities)
+	assert.Equal(t, []cosmos.Direction{cosmos.West}, path.Directions)
+	assert.Equal(t, []bool{false}, path.OneWay)
+
+	assert.EqualError(t, FindPath(&out, filename, "Foo", "Qux", false), "No road leads from Foo to Qux")
+}
+
+func TestWriteDistances(t *testing.T) {
+	dir := t.TempDir()
+	filename := writeFile(t, dir, "map.txt", "Foo north=Bar\nBar east->Baz\nQux\n")
+
+	var out bytes.Buffer
+	require.Nil(t, WriteDistances(&out, filename, nil, false))
+	assert.Equal(t, "     Foo  Bar  Baz  Qux\nFoo  0    1    2    -\nBar  1    0    1    -\nBaz  -    -    0    -\nQux  -    -    -    0\n", out.String())
+
+	out.Reset()
+	require.Nil(t, WriteDistances(&out, filename, []string{"Baz", "Foo"}, true))
+	var distances Distances
+	require.Nil(t, json.Unmarshal(out.Bytes(), &distances))
+	assert.Equal(t, []string{"Baz", "Foo"}, distances.Cities)
+	assert.Equal(t, [][]int{{0, -1}, {2, 0}}, distances.Distances)
+
+	assert.EqualError(t, WriteDistances(&out, filename, []string{"Foo", "Nowhere"}, false), "Couldn't find city Nowhere")
+}
@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -9,38 +8,74 @@ import (
 )
 
 var file string
+var directions string
 var N int
+var seed int64
+var strategy string
+var fightThreshold int
+var maxRounds int
+var output string
+var events string
+var quiet bool
+var summary bool
+var checkInvariants bool
+var workers int
+var checkpointEvery int
+var checkpointDir string
+var history string
+
+// config is the effective configuration, loaded before any command runs
+var config Config
 
 // RootCmd is the basic command for Aliens
 var RootCmd = &cobra.Command{
 	Use:   "aliens",
 	Short: "Run simulation of a battle of aliens",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		l, err := NewLogger(os.Stderr, cfg.LogLevel, cfg.LogFormat)
+		if err != nil {
+			return err
+		}
+		config = cfg
+		logger = l
+		return nil
+	},
 	Run: func(cmd *cobra.Command, args []string) {
-		err := Init(file, N)
+		err := Init(config)
 		if err != nil {
-			fmt.Println(err)
+			logger.Error(err.Error())
 			os.Exit(1)
 		}
 	},
 }
 
-// var testCmd = &cobra.Command{
-// 	Use:   "test",
-// 	Short: "Run tests for the alien task",
-// 	Run: func(cmd *cobra.Command, args []string) {
-// 		// TODO
-// 		// run tests N
-// 	},
-// }
-
 func init() {
-	// RootCmd.AddCommand(testCmd)
-	RootCmd.PersistentFlags().IntVarP(&N, "N", "N", 10, "Number of aliens placed in the map")
-	RootCmd.Flags().StringVarP(&file, "file", "f", "example.txt", "Full path to the .txt file containing the map")
-	RootCmd.MarkFlagRequired("file")
-	RootCmd.MarkFlagRequired("N")
-	// testCmd.MarkFlagRequired("N")
-	viper.BindPFlag("file", RootCmd.Flags().Lookup("file"))
-	viper.BindPFlag("N", RootCmd.Flags().Lookup("N"))
-	// viper.BindPFlag("N", testCmd.Flags().Lookup("N"))
+	flags := RootCmd.PersistentFlags()
+	flags.StringVar(&cfgFile, "config", "", "Config file (e.g. aliens.yaml) with any of the options below")
+	flags.StringVarP(&file, "file", "f", "", "Map file in the .txt format, optionally gzip-compressed as .txt.gz, or - to read it from stdin")
+	flags.StringVar(&directions, "directions", "4", "Directions the roads of the map can take: 4, 8 (with the diagonals), hex or 3d (with up and down)")
+	flags.IntVarP(&N, "N", "N", 10, "Number of aliens placed in the map")
+	flags.Int64Var(&seed, "seed", 0, "Seed of the random placement and moves, 0 picks one from the clock")
+	flags.StringVar(&strategy, "strategy", "random", "How aliens choose their roads: random or first")
+	flags.IntVar(&fightThreshold, "fight-threshold", 2, "Aliens that must meet in a city to fight")
+	flags.IntVar(&maxRounds, "max-rounds", 10000, "Rounds after which the simulation stops")
+	flags.StringVar(&output, "output", "full", "What is printed on stdout: full, quiet, summary or json")
+	flags.StringVar(&events, "events", "", "File where the events of the simulation are logged as JSON lines")
+	flags.StringVar(&logLevel, "log-level", "info", "Minimum level of the logs: debug, info, warn or error")
+	flags.StringVar(&logFormat, "log-format", "text", "Format of the logs written to stderr: text or json")
+	flags.IntVar(&workers, "workers", 0, "Split the map in regions simulated by this many workers in parallel, 0 runs the sequential engine")
+	flags.BoolVar(&checkInvariants, "check-invariants", false, "Check the consistency of the map after every step, which slows the simulation down")
+	flags.IntVar(&checkpointEvery, "checkpoint-every", 0, "Save a checkpoint every this many rounds, to resume the simulation with aliens resume, 0 saves none")
+	flags.StringVar(&checkpointDir, "checkpoint-dir", ".", "Directory where the checkpoints are saved as checkpoint-<round>.bin")
+	flags.StringVar(&history, "history", defaultHistory(), "Database where every run is recorded, empty records none")
+	RootCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Print only the surviving map, same as --output=quiet")
+	RootCmd.Flags().BoolVar(&summary, "summary", false, "Print only the final counts, same as --output=summary")
+	RootCmd.MarkFlagsMutuallyExclusive("quiet", "summary")
+	for _, key := range []string{"file", "directions", "N", "seed", "strategy", "fight-threshold", "max-rounds", "output", "events", "log-level", "log-format", "check-invariants", "workers", "checkpoint-every", "checkpoint-dir", "history"} {
+		viper.BindPFlag(key, flags.Lookup(key))
+	}
 }
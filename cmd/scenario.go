@@ -0,0 +1,265 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fedekunze/alien_task/cosmos"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is a self-describing battle: the map, where the aliens start, the
+// rules and the outcome expected. Scenarios are written in YAML or JSON:
+//
+//	name: two cities
+//	map: |
+//	  Foo east=Bar
+//	aliens: [Foo, Bar]
+//	seed: 42
+//	expect:
+//	  aliens-left: 0
+//	  destroyed: [Bar]
+type Scenario struct {
+	Name           string   `yaml:"name"`
+	Map            string   `yaml:"map"`             // inline map in the .txt format
+	MapFile        string   `yaml:"map-file"`        // map file, relative to the scenario
+	Directions     string   `yaml:"directions"`      // of the roads of the map, 4 by default
+	Aliens         []string `yaml:"aliens"`          // city where each alien starts
+	N              int      `yaml:"N"`               // aliens placed at random, if no aliens are listed
+	Seed           int64    `yaml:"seed"`            // 0 picks one from the clock
+	Strategy       string   `yaml:"strategy"`        // random by default
+	FightThreshold int      `yaml:"fight-threshold"` // 2 by default
+	MaxRounds      int      `yaml:"max-rounds"`      // 10,000 by default
+	Expect         Expect   `yaml:"expect"`
+
+	dir string // directory of the scenario file
+}
+
+// Expect lists the assertions on the outcome of a scenario. Empty fields are
+// not checked.
+type Expect struct {
+	AliensLeft  *int     `yaml:"aliens-left"`  // exact number of aliens alive at the end
+	Destroyed   []string `yaml:"destroyed"`    // cities that must be destroyed
+	Survive     []string `yaml:"survive"`      // cities that must not be destroyed
+	Alive       []int    `yaml:"alive"`        // aliens that must be alive at the end
+	RoundsBelow int      `yaml:"rounds-below"` // the battle must end before this round
+}
+
+// Outcome is the final state of a scenario
+type Outcome struct {
+	AliensLeft int
+	Rounds     int
+	Seed       int64
+	Destroyed  []string // destroyed cities, in the order they appear in the map
+	Map        *cosmos.Map
+}
+
+// LoadScenario reads a scenario from a YAML or JSON file
+func LoadScenario(filename string) (*Scenario, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var sc Scenario
+	err = dec.Decode(&sc)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid scenario %v: %v", filename, err)
+	}
+	if sc.Name == "" {
+		sc.Name = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+	sc.dir = filepath.Dir(filename)
+	return &sc, nil
+}
+
+// Rules returns the rules of the scenario, using the defaults for any rule
+// that is not set. The invariants are checked if --check-invariants is set.
+func (sc *Scenario) Rules() cosmos.Config {
+	rules := cosmos.DefaultConfig()
+	rules.Seed = sc.Seed
+	if sc.Strategy != "" {
+		rules.Strategy = cosmos.Strategy(sc.Strategy)
+	}
+	if sc.FightThreshold != 0 {
+		rules.FightThreshold = sc.FightThreshold
+	}
+	if sc.MaxRounds != 0 {
+		rules.MaxRounds = sc.MaxRounds
+	}
+	rules.CheckInvariants = config.CheckInvariants
+	return rules
+}
+
+// Battle builds the map of the scenario, places its aliens and creates the
+// simulation that runs them
+func (sc *Scenario) Battle() (*cosmos.Map, *cosmos.Simulation, error) {
+	m, err := createMap(sc.Directions)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case sc.Map != "" && sc.MapFile != "":
+		return nil, nil, fmt.Errorf("Scenario %v has both an inline map and a map file", sc.Name)
+	case sc.MapFile != "":
+		err = ReadMap(sc.mapFile(), m)
+	default:
+		err = ParseMap(strings.NewReader(sc.Map), m)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	totalAliens := sc.N
+	if len(sc.Aliens) > 0 {
+		totalAliens = len(sc.Aliens)
+	}
+	sim := cosmos.NewSimulation(m, totalAliens)
+	err = sim.SetConfig(sc.Rules())
+	if err != nil {
+		return nil, nil, err
+	}
+	sim.SetLogger(logger)
+	if len(sc.Aliens) > 0 {
+		err = PlaceAliensIn(m, sc.Aliens)
+	} else {
+		err = PlaceAliens(m, totalAliens, placementRNG(sim.Seed()))
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return m, sim, nil
+}
+
+// Run runs the scenario, reporting the battle to w in the given output mode
+// and recording it in the history of the configuration
+func (sc *Scenario) Run(w io.Writer, mode OutputMode) (Outcome, error) {
+	m, sim, err := sc.Battle()
+	if err != nil {
+		return Outcome{}, err
+	}
+	var recorder *runRecorder
+	if config.History != "" {
+		rec := rulesRecord(len(m.Aliens), sim.Seed(), sc.Rules())
+		if sc.MapFile != "" {
+			rec.Map = absPath(sc.mapFile())
+		}
+		recorder = newRunRecorder(m, sim, rec)
+	}
+	report := NewReport(w, mode)
+	sim.Subscribe(report.Listen)
+	aliensLeft, round, err := sim.Run()
+	recordRun(config.History, recorder, sim, err)
+	if err != nil {
+		return Outcome{}, err
+	}
+	report.Print(m, aliensLeft, round)
+	outcome := Outcome{
+		AliensLeft: aliensLeft,
+		Rounds:     round,
+		Seed:       sim.Seed(),
+		Map:        m,
+	}
+	for i := 0; i < m.CitiesLen(); i++ {
+		if m.Graph().IsDestroyed(i) {
+			outcome.Destroyed = append(outcome.Destroyed, m.CityName(i))
+		}
+	}
+	return outcome, nil
+}
+
+// Check returns a description of every expectation the outcome does not meet
+func (e Expect) Check(outcome Outcome) []string {
+	var failures []string
+	if e.AliensLeft != nil && *e.AliensLeft != outcome.AliensLeft {
+		failures = append(failures, fmt.Sprintf("expected %v aliens left, got %v", *e.AliensLeft, outcome.AliensLeft))
+	}
+	for _, name := range e.Destroyed {
+		city, err := outcome.Map.GetCity(name)
+		if err != nil {
+			failures = append(failures, err.Error())
+		} else if !city.IsDestroyed() {
+			failures = append(failures, "expected "+name+" to be destroyed")
+		}
+	}
+	for _, name := range e.Survive {
+		city, err := outcome.Map.GetCity(name)
+		if err != nil {
+			failures = append(failures, err.Error())
+		} else if city.IsDestroyed() {
+			failures = append(failures, "expected "+name+" to survive")
+		}
+	}
+	for _, id := range e.Alive {
+		alien, err := outcome.Map.Aliens.Get(id)
+		if err != nil {
+			failures = append(failures, err.Error())
+		} else if !alien.IsAlive() {
+			failures = append(failures, fmt.Sprintf("expected alien %v to be alive", id))
+		}
+	}
+	if e.RoundsBelow > 0 && outcome.Rounds >= e.RoundsBelow {
+		failures = append(failures, fmt.Sprintf("expected to end before round %v, ended at round %v", e.RoundsBelow, outcome.Rounds))
+	}
+	return failures
+}
+
+// mapFile returns the path of the map file of the scenario, relative to the
+// scenario
+func (sc *Scenario) mapFile() string {
+	if filepath.IsAbs(sc.MapFile) {
+		return sc.MapFile
+	}
+	return filepath.Join(sc.dir, sc.MapFile)
+}
+
+// PlaceAliensIn places an alien in each of the given cities, with the
+// position in the list as its id
+func PlaceAliensIn(m *cosmos.Map, cities []string) error {
+	for index, name := range cities {
+		city, err := m.GetCity(name)
+		if err != nil {
+			return err
+		}
+		alien := cosmos.NewAlien(index, city)
+		city.AddAlien(alien)
+		m.Aliens.Set(index, alien)
+	}
+	return nil
+}
+
+// runCmd runs a scenario file
+var runCmd = &cobra.Command{
+	Use:   "run <scenario.yaml>",
+	Short: "Run a scenario bundling a map, the placement of the aliens and the rules",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		sc, err := LoadScenario(args[0])
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		logger.Info("Running scenario...", "scenario", sc.Name)
+		outcome, err := sc.Run(os.Stdout, OutputMode(config.Output))
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		failures := sc.Expect.Check(outcome)
+		for _, failure := range failures {
+			logger.Warn("Unexpected outcome", "scenario", sc.Name, "seed", outcome.Seed, "reason", failure)
+		}
+		if len(failures) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(runCmd)
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFile writes a file in dir and returns its path
+func writeFile(t *testing.T, dir string, name string, content string) string {
+	path := filepath.Join(dir, name)
+	require.Nil(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestScenarioInlineMap(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "duel.yaml", `
+name: duel
+map: |
+  Foo east=Bar
+  Bar east=Baz
+aliens: [Foo, Baz]
+strategy: first
+seed: 3
+expect:
+  aliens-left: 0
+  destroyed: [Bar]
+  survive: [Foo, Baz]
+`)
+	sc, err := LoadScenario(path)
+	require.Nil(t, err)
+	assert.Equal(t, "duel", sc.Name)
+	var buf bytes.Buffer
+	outcome, err := sc.Run(&buf, SummaryOutput)
+	require.Nil(t, err)
+	// the alien in Foo goes east and the one in Baz goes west to Bar
+	assert.Equal(t, []string{"Bar"}, outcome.Destroyed)
+	assert.Equal(t, 1, outcome.Rounds)
+	assert.Equal(t, int64(3), outcome.Seed)
+	assert.Empty(t, sc.Expect.Check(outcome))
+	assert.Equal(t, "aliens_left=0\ncities_destroyed=1\nrounds=1\n", buf.String())
+
+	sc.Expect.Survive = []string{"Bar", "Qux"}
+	assert.Equal(t, []string{"expected Bar to survive", "Couldn't find city Qux"}, sc.Expect.Check(outcome))
+}
+
+func TestScenarioMapFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "map.txt", "Foo north=Bar west=Baz\n")
+	path := writeFile(t, dir, "random.json", `{"map-file": "map.txt", "N": 5, "seed": 11, "max-rounds": 20}`)
+	sc, err := LoadScenario(path)
+	require.Nil(t, err)
+	assert.Equal(t, "random", sc.Name)
+	first, err := sc.Run(&bytes.Buffer{}, QuietOutput)
+	require.Nil(t, err)
+	second, err := sc.Run(&bytes.Buffer{}, QuietOutput)
+	require.Nil(t, err)
+	// the seed reproduces the same battle
+	assert.Equal(t, first.Destroyed, second.Destroyed)
+	assert.Equal(t, first.AliensLeft, second.AliensLeft)
+	assert.Equal(t, first.Rounds, second.Rounds)
+	assert.True(t, first.Rounds <= 20)
+}
+
+func TestScenarioHistory(t *testing.T) {
+	dir := t.TempDir()
+	config.History = filepath.Join(dir, "history.db")
+	t.Cleanup(func() { config.History = "" })
+	writeFile(t, dir, "map.txt", "Foo east=Bar\nBar east=Baz\n")
+	path := writeFile(t, dir, "duel.yaml", "map-file: map.txt\naliens: [Foo, Baz]\nstrategy: first\nseed: 3\n")
+	sc, err := LoadScenario(path)
+	require.Nil(t, err)
+	_, err = sc.Run(&bytes.Buffer{}, QuietOutput)
+	require.Nil(t, err)
+	failed, err := CheckScenarios(&bytes.Buffer{}, []string{path})
+	require.Nil(t, err)
+	assert.Equal(t, 0, failed)
+
+	// both the scenario and its test are recorded
+	runs := historyRecords(t, config.History)
+	require.Len(t, runs, 2)
+	for _, rec := range runs {
+		assert.Equal(t, filepath.Join(dir, "map.txt"), rec.Map)
+		assert.Equal(t, int64(3), rec.Seed)
+		assert.Equal(t, 2, rec.Aliens)
+		assert.Equal(t, "first", rec.Strategy)
+		assert.Equal(t, []string{"Bar"}, rec.Destroyed)
+		assert.Equal(t, 1, rec.Rounds)
+	}
+}
+
+func TestScenarioInvalid(t *testing.T) {
+	dir := t.TempDir()
+	_, err := LoadScenario(writeFile(t, dir, "typo.yaml", "mapp: Foo east=Bar\n"))
+	assert.Error(t, err)
+	sc, err := LoadScenario(writeFile(t, dir, "unknown.yaml", "map: Foo east=Bar\naliens: [Qux]\n"))
+	require.Nil(t, err)
+	_, _, err = sc.Battle()
+	assert.Error(t, err)
+}
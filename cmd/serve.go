@@ -0,0 +1,393 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fedekunze/alien_task/cosmos"
+	"github.com/spf13/cobra"
+)
+
+var addr string
+var bufferSize int
+var retention time.Duration
+
+// serveCmd runs simulations over HTTP and streams their events to clients
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run simulations over HTTP and stream their events",
+	Run: func(cmd *cobra.Command, args []string) {
+		logger.Info("Listening...", "addr", addr)
+		srv := NewServer(bufferSize)
+		srv.SetHistory(config.History)
+		srv.SetRetention(retention)
+		err := http.ListenAndServe(addr, srv)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().StringVar(&addr, "addr", ":8080", "Address to listen on")
+	serveCmd.Flags().IntVar(&bufferSize, "buffer", 1024, "Events buffered per client before it is dropped")
+	serveCmd.Flags().DurationVar(&retention, "retention", DefaultRetention, "How long finished simulations are kept, 0 keeps them until deleted")
+}
+
+// run is a simulation created through the server
+type run struct {
+	id     int
+	sim    *cosmos.Simulation
+	stream *Stream
+	tick   time.Duration // pause after every move, so clients can follow the battle
+
+	history  string       // history where the run is recorded once done, if any
+	recorder *runRecorder // record of the run in the history
+
+	ctx    context.Context // done once the simulation is stopped
+	cancel context.CancelFunc
+
+	mu         sync.Mutex
+	started    bool
+	done       bool
+	aliensLeft int // only set once done, the simulation tracks it while running
+	round      int
+	err        error
+}
+
+// runStatus is the JSON representation of a run
+type runStatus struct {
+	ID         int    `json:"id"`
+	Started    bool   `json:"started"`
+	Done       bool   `json:"done"`
+	AliensLeft int    `json:"aliens_left"`
+	Round      int    `json:"round"`
+	Error      string `json:"error,omitempty"`
+}
+
+func (r *run) status() runStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started && !r.done {
+		r.aliensLeft, r.round = r.sim.Progress()
+	}
+	st := runStatus{
+		ID:         r.id,
+		Started:    r.started,
+		Done:       r.done,
+		AliensLeft: r.aliensLeft,
+		Round:      r.round,
+	}
+	if r.err != nil {
+		st.Error = r.err.Error()
+	}
+	return st
+}
+
+// start runs the simulation in the background, publishing its events, and
+// calls finished once it is done
+func (r *run) start(finished func()) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started {
+		return fmt.Errorf("Simulation %v has already started", r.id)
+	}
+	r.started = true
+	go func() {
+		aliensLeft, round, err := r.sim.RunContext(r.ctx)
+		recordRun(r.history, r.recorder, r.sim, err)
+		r.mu.Lock()
+		r.done = true
+		r.aliensLeft, r.round, r.err = aliensLeft, round, err
+		r.mu.Unlock()
+		r.stream.Close()
+		finished()
+	}()
+	return nil
+}
+
+// stop stops the simulation if it is running, and ends the streams of its
+// events
+func (r *run) stop() {
+	r.cancel()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.started {
+		r.stream.Close()
+	}
+}
+
+// Server exposes the simulator over HTTP:
+//
+//	POST /simulations?N=10          creates a simulation with the map in the body
+//	POST /simulations/{id}/start    starts the simulation
+//	GET  /simulations/{id}          returns the status of the simulation
+//	GET  /simulations/{id}/cities/{name}  returns the state of a city
+//	GET  /simulations/{id}/aliens/{alien} returns the state of an alien
+//	GET  /simulations/{id}/events   streams its events as Server-Sent Events
+//	DELETE /simulations/{id}        stops the simulation and forgets it
+//	GET  /metrics                   exposes the metrics of the engine
+//
+// Finished simulations are forgotten after the retention of the server.
+type Server struct {
+	mu        sync.Mutex
+	runs      map[int]*run
+	nextID    int
+	buffer    int
+	history   string        // history where every run is recorded, if any
+	retention time.Duration // how long finished runs are kept, 0 for ever
+	mux       *http.ServeMux
+}
+
+// DefaultRetention is how long a server keeps its finished simulations
+const DefaultRetention = 10 * time.Minute
+
+// NewServer creates a server where every client buffers up to buffer events
+func NewServer(buffer int) *Server {
+	srv := &Server{
+		runs:      make(map[int]*run),
+		buffer:    buffer,
+		retention: DefaultRetention,
+		mux:       http.NewServeMux(),
+	}
+	srv.mux.HandleFunc("POST /simulations", srv.create)
+	srv.mux.HandleFunc("POST /simulations/{id}/start", srv.start)
+	srv.mux.HandleFunc("GET /simulations/{id}", srv.get)
+	srv.mux.HandleFunc("GET /simulations/{id}/events", srv.events)
+	srv.mux.HandleFunc("GET /simulations/{id}/cities/{name}", srv.city)
+	srv.mux.HandleFunc("GET /simulations/{id}/aliens/{alien}", srv.alien)
+	srv.mux.HandleFunc("DELETE /simulations/{id}", srv.delete)
+	srv.mux.Handle("GET /metrics", MetricsHandler(cosmos.DefaultMetrics))
+	return srv
+}
+
+// SetHistory sets the history where every run of the server is recorded,
+// none if empty
+func (srv *Server) SetHistory(history string) {
+	srv.history = history
+}
+
+// SetRetention sets how long finished simulations are kept, for ever if zero
+func (srv *Server) SetRetention(retention time.Duration) {
+	srv.retention = retention
+}
+
+func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	srv.mux.ServeHTTP(w, r)
+}
+
+// lookup finds the run for the id in the path of the request
+func (srv *Server) lookup(r *http.Request) (*run, error) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid simulation id %v", r.PathValue("id"))
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	sim, ok := srv.runs[id]
+	if !ok {
+		return nil, fmt.Errorf("Couldn't find simulation %v", id)
+	}
+	return sim, nil
+}
+
+func (srv *Server) create(w http.ResponseWriter, r *http.Request) {
+	totalAliens, err := strconv.Atoi(r.URL.Query().Get("N"))
+	if err != nil {
+		http.Error(w, "Invalid number of aliens", http.StatusBadRequest)
+		return
+	}
+	if totalAliens < 0 {
+		http.Error(w, "Number of aliens can't be negative", http.StatusBadRequest)
+		return
+	}
+	var tick time.Duration
+	if t := r.URL.Query().Get("tick"); t != "" {
+		tick, err = time.ParseDuration(t)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	m, err := createMap(r.URL.Query().Get("directions"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = ParseMap(r.Body, m)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	battle, err := NewBattle(m, totalAliens, cosmos.DefaultConfig())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	sim := &run{
+		ctx:     ctx,
+		cancel:  cancel,
+		sim:     battle,
+		stream:  NewStream(),
+		tick:    tick,
+		history: srv.history,
+	}
+	if sim.history != "" {
+		sim.recorder = newRunRecorder(m, battle, rulesRecord(totalAliens, battle.Seed(), cosmos.DefaultConfig()))
+	}
+	sim.sim.Subscribe(func(e cosmos.Event) {
+		sim.stream.Publish(e)
+		if e.Type == cosmos.MoveEvent && sim.tick > 0 {
+			select {
+			case <-time.After(sim.tick):
+			case <-sim.ctx.Done():
+			}
+		}
+	})
+	srv.mu.Lock()
+	sim.id = srv.nextID
+	srv.nextID++
+	srv.runs[sim.id] = sim
+	srv.mu.Unlock()
+	logger.Info("Created simulation", "id", sim.id, "aliens", totalAliens, "cities", m.CitiesLen())
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(sim.status())
+}
+
+func (srv *Server) start(w http.ResponseWriter, r *http.Request) {
+	sim, err := srv.lookup(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	err = sim.start(func() {
+		if srv.retention > 0 {
+			time.AfterFunc(srv.retention, func() { srv.forget(sim) })
+		}
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	json.NewEncoder(w).Encode(sim.status())
+}
+
+// delete stops a simulation and forgets it
+func (srv *Server) delete(w http.ResponseWriter, r *http.Request) {
+	sim, err := srv.lookup(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	srv.forget(sim)
+	sim.stop()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// forget removes a run from the server, along with its events
+func (srv *Server) forget(sim *run) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.runs[sim.id] == sim {
+		delete(srv.runs, sim.id)
+	}
+}
+
+func (srv *Server) get(w http.ResponseWriter, r *http.Request) {
+	sim, err := srv.lookup(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(sim.status())
+}
+
+// city returns the state of a city, which can be read while the simulation runs
+func (srv *Server) city(w http.ResponseWriter, r *http.Request) {
+	sim, err := srv.lookup(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	city, err := sim.sim.City(r.PathValue("name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(city)
+}
+
+// alien returns the state of an alien, which can be read while the simulation runs
+func (srv *Server) alien(w http.ResponseWriter, r *http.Request) {
+	sim, err := srv.lookup(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("alien"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid alien id %v", r.PathValue("alien")), http.StatusBadRequest)
+		return
+	}
+	alien, err := sim.sim.Alien(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(alien)
+}
+
+// events streams the events of a simulation. The stream ends with an "end"
+// event when the simulation finishes, or with an "overflow" event when the
+// client could not keep up with the simulation and was dropped.
+func (srv *Server) events(w http.ResponseWriter, r *http.Request) {
+	sim, err := srv.lookup(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	sub := sim.stream.Subscribe(srv.buffer)
+	defer sub.Cancel()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				if sub.Dropped() {
+					writeEvent(w, "overflow", sim.status())
+				} else {
+					writeEvent(w, "end", sim.status())
+				}
+				flusher.Flush()
+				return
+			}
+			writeEvent(w, string(e.Type), e)
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes a single Server-Sent Event with a JSON payload
+func writeEvent(w http.ResponseWriter, name string, data interface{}) {
+	payload, _ := json.Marshal(data)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, payload)
+}
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fedekunze/alien_task/cosmos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// getJSON decodes the JSON response of a GET request
+func getJSON(t *testing.T, url string, v interface{}) int {
+	res, err := http.Get(url)
+	require.Nil(t, err)
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusOK {
+		require.Nil(t, json.NewDecoder(res.Body).Decode(v))
+	}
+	return res.StatusCode
+}
+
+func TestServerReadsDuringRun(t *testing.T) {
+	srv := httptest.NewServer(NewServer(1024))
+	defer srv.Close()
+	// two aliens on a tree always meet, which ends the battle
+	res, err := http.Post(srv.URL+"/simulations?N=2&tick=1ms", "text/plain", strings.NewReader(testMap))
+	require.Nil(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	res, err = http.Post(srv.URL+"/simulations/0/start", "text/plain", nil)
+	require.Nil(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	// query the cities and aliens until the battle is over
+	for {
+		var city cosmos.CityState
+		assert.Equal(t, http.StatusOK, getJSON(t, srv.URL+"/simulations/0/cities/Foo", &city))
+		assert.Equal(t, "Foo", city.Name)
+		var alien cosmos.AlienState
+		assert.Equal(t, http.StatusOK, getJSON(t, srv.URL+"/simulations/0/aliens/1", &alien))
+		assert.Equal(t, 1, alien.ID)
+		assert.NotEmpty(t, alien.City)
+		var st runStatus
+		assert.Equal(t, http.StatusOK, getJSON(t, srv.URL+"/simulations/0", &st))
+		assert.LessOrEqual(t, st.AliensLeft, 2)
+		if st.Done {
+			break
+		}
+	}
+
+	assert.Equal(t, http.StatusNotFound, getJSON(t, srv.URL+"/simulations/0/cities/Qux", nil))
+	assert.Equal(t, http.StatusNotFound, getJSON(t, srv.URL+"/simulations/0/aliens/42", nil))
+	assert.Equal(t, http.StatusBadRequest, getJSON(t, srv.URL+"/simulations/0/aliens/x", nil))
+	assert.Equal(t, http.StatusNotFound, getJSON(t, srv.URL+"/simulations/1/cities/Foo", nil))
+}
+
+func TestServerHistory(t *testing.T) {
+	server := NewServer(1024)
+	history := filepath.Join(t.TempDir(), "history.db")
+	server.SetHistory(history)
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+	res, err := http.Post(srv.URL+"/simulations?N=2", "text/plain", strings.NewReader(testMap))
+	require.Nil(t, err)
+	res.Body.Close()
+	res, err = http.Post(srv.URL+"/simulations/0/start", "text/plain", nil)
+	require.Nil(t, err)
+	res.Body.Close()
+	var st runStatus
+	for !st.Done {
+		require.Equal(t, http.StatusOK, getJSON(t, srv.URL+"/simulations/0", &st))
+	}
+
+	runs := historyRecords(t, history)
+	require.Len(t, runs, 1)
+	assert.Equal(t, 2, runs[0].Aliens)
+	assert.Equal(t, 5, runs[0].Cities)
+	assert.Equal(t, st.Round, runs[0].Rounds)
+	assert.Equal(t, st.AliensLeft, runs[0].AliensLeft)
+}
+
+// request sends a request without a body and returns its status
+func request(t *testing.T, method string, url string) int {
+	req, err := http.NewRequest(method, url, nil)
+	require.Nil(t, err)
+	res, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	res.Body.Close()
+	return res.StatusCode
+}
+
+// createRun creates a simulation of the test map on the server and returns
+// its status
+func createRun(t *testing.T, url string, query string) runStatus {
+	res, err := http.Post(url+"/simulations?"+query, "text/plain", strings.NewReader(testMap))
+	require.Nil(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	var st runStatus
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&st))
+	return st
+}
+
+func TestServerCreateInvalid(t *testing.T) {
+	srv := httptest.NewServer(NewServer(1024))
+	defer srv.Close()
+	for _, query := range []string{"N=-1", "N=x", "N=2&tick=soon", "N=2&directions=octagonal"} {
+		res, err := http.Post(srv.URL+"/simulations?"+query, "text/plain", strings.NewReader(testMap))
+		require.Nil(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode, query)
+	}
+}
+
+func TestServerDelete(t *testing.T) {
+	srv := httptest.NewServer(NewServer(1024))
+	defer srv.Close()
+	assert.Equal(t, 0, createRun(t, srv.URL, "N=2").ID)
+	assert.Equal(t, http.StatusNoContent, request(t, http.MethodDelete, srv.URL+"/simulations/0"))
+	assert.Equal(t, http.StatusNotFound, getJSON(t, srv.URL+"/simulations/0", nil))
+	assert.Equal(t, http.StatusNotFound, request(t, http.MethodDelete, srv.URL+"/simulations/0"))
+	// ids are not reused
+	assert.Equal(t, 1, createRun(t, srv.URL, "N=2").ID)
+
+	// a running simulation is stopped, which ends its events
+	running := createRun(t, srv.URL, "N=2&tick=1h")
+	path := srv.URL + "/simulations/" + strconv.Itoa(running.ID)
+	require.Equal(t, http.StatusOK, request(t, http.MethodPost, path+"/start"))
+	res, err := http.Get(path + "/events")
+	require.Nil(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusNoContent, request(t, http.MethodDelete, path))
+	body, err := io.ReadAll(res.Body)
+	require.Nil(t, err)
+	assert.Contains(t, string(body), "event: end\n")
+	assert.Contains(t, string(body), "Simulation stopped")
+}
+
+func TestServerRetention(t *testing.T) {
+	server := NewServer(1024)
+	server.SetRetention(time.Millisecond)
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+	createRun(t, srv.URL, "N=2")
+	// an unfinished simulation is kept
+	time.Sleep(10 * time.Millisecond)
+	require.Equal(t, http.StatusOK, getJSON(t, srv.URL+"/simulations/0", &runStatus{}))
+	require.Equal(t, http.StatusOK, request(t, http.MethodPost, srv.URL+"/simulations/0/start"))
+	assert.Eventually(t, func() bool {
+		return getJSON(t, srv.URL+"/simulations/0", &runStatus{}) == http.StatusNotFound
+	}, time.Second, time.Millisecond)
+}
+
+// pausedWriter records a response and holds the handler in its first flush
+// until released
+type pausedWriter struct {
+	*httptest.ResponseRecorder
+	once    sync.Once
+	paused  chan struct{}
+	release chan struct{}
+}
+
+func (w *pausedWriter) Flush() {
+	w.once.Do(func() {
+		close(w.paused)
+		<-w.release
+	})
+	w.ResponseRecorder.Flush()
+}
+
+func TestServerEventsOverflow(t *testing.T) {
+	srv := NewServer(1)
+	m := cosmos.CreateMap()
+	require.Nil(t, ParseMap(strings.NewReader(testMap), m))
+	battle, err := NewBattle(m, 2, cosmos.DefaultConfig())
+	require.Nil(t, err)
+	sim := &run{sim: battle, stream: NewStream()}
+	srv.runs[0] = sim
+
+	w := &pausedWriter{ResponseRecorder: httptest.NewRecorder(), paused: make(chan struct{}), release: make(chan struct{})}
+	done := make(chan struct{})
+	go func() {
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/simulations/0/events", nil))
+		close(done)
+	}()
+	<-w.paused
+	// the client falls behind just before the battle ends
+	for i := 0; i < 3; i++ {
+		sim.stream.Publish(cosmos.Event{Type: cosmos.MoveEvent, Round: i})
+	}
+	sim.mu.Lock()
+	sim.started, sim.done = true, true
+	sim.mu.Unlock()
+	sim.stream.Close()
+	close(w.release)
+	<-done
+	assert.Contains(t, w.Body.String(), "event: move\n")
+	assert.Contains(t, w.Body.String(), "event: overflow\n")
+	assert.NotContains(t, w.Body.String(), "event: end\n")
+
+	// a client that connects once the battle is over only gets its end
+	w = &pausedWriter{ResponseRecorder: httptest.NewRecorder(), paused: make(chan struct{}), release: make(chan struct{})}
+	close(w.release)
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/simulations/0/events", nil))
+	assert.True(t, strings.HasPrefix(w.Body.String(), "event: end\n"), w.Body.String())
+}
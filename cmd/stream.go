@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"sync"
+
+	"github.com/fedekunze/alien_task/cosmos"
+)
+
+// Stream fans out the events of a running simulation to its subscribers.
+// Publishing never blocks: each subscriber owns a bounded buffer and a
+// subscriber that falls behind is dropped instead of stalling the simulation.
+type Stream struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// Subscription is a subscriber of a stream
+type Subscription struct {
+	C <-chan cosmos.Event // closed when the subscription ends
+
+	stream  *Stream
+	ch      chan cosmos.Event
+	dropped bool // the subscriber fell behind, set before closing the channel
+}
+
+// NewStream creates an empty stream of events
+func NewStream() *Stream {
+	return &Stream{
+		subs: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish sends the event to every subscriber. Subscribers with a full
+// buffer are dropped: their channel is closed and they are removed from the
+// stream.
+func (s *Stream) Publish(e cosmos.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sub := range s.subs {
+		select {
+		case sub.ch <- e:
+		default:
+			sub.dropped = true
+			s.remove(sub)
+		}
+	}
+}
+
+// Subscribe returns a subscription to the events published from now on. Its
+// channel is closed when the stream ends, when the subscription is cancelled
+// or when the subscriber falls more than size events behind.
+func (s *Stream) Subscribe(size int) *Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch := make(chan cosmos.Event, size)
+	sub := &Subscription{C: ch, stream: s, ch: ch}
+	if s.closed {
+		close(ch)
+		return sub
+	}
+	s.subs[sub] = struct{}{}
+	return sub
+}
+
+// Close ends the stream and closes the channels of all subscribers
+func (s *Stream) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sub := range s.subs {
+		s.remove(sub)
+	}
+	s.closed = true
+}
+
+// Cancel ends the subscription and closes its channel, if it is still open
+func (sub *Subscription) Cancel() {
+	sub.stream.mu.Lock()
+	defer sub.stream.mu.Unlock()
+	if _, ok := sub.stream.subs[sub]; ok {
+		sub.stream.remove(sub)
+	}
+}
+
+// Dropped checks that the channel was closed because the subscriber fell
+// behind, rather than because the stream ended or it was cancelled
+func (sub *Subscription) Dropped() bool {
+	sub.stream.mu.Lock()
+	defer sub.stream.mu.Unlock()
+	return sub.dropped
+}
+
+// ----- Unexported functions -----
+
+// remove closes the channel of a subscriber and removes it from the stream,
+// with the lock already held
+func (s *Stream) remove(sub *Subscription) {
+	delete(s.subs, sub)
+	close(sub.ch)
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/fedekunze/alien_task/cosmos"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamPublish(t *testing.T) {
+	stream := NewStream()
+	sub := stream.Subscribe(2)
+	defer sub.Cancel()
+	events := sub.C
+	stream.Publish(cosmos.Event{Type: cosmos.MoveEvent, Round: 0})
+	stream.Publish(cosmos.Event{Type: cosmos.MoveEvent, Round: 1})
+	e := <-events
+	assert.Equal(t, 0, e.Round)
+	e = <-events
+	assert.Equal(t, 1, e.Round)
+	stream.Close()
+	_, ok := <-events
+	assert.False(t, ok)
+	assert.False(t, sub.Dropped())
+	// subscribing to a closed stream ends at once
+	_, ok = <-stream.Subscribe(2).C
+	assert.False(t, ok)
+}
+
+func TestStreamSlowSubscriber(t *testing.T) {
+	stream := NewStream()
+	slowSub := stream.Subscribe(1)
+	defer slowSub.Cancel()
+	fastSub := stream.Subscribe(3)
+	defer fastSub.Cancel()
+	slow, fast := slowSub.C, fastSub.C
+	// publishing must never block, even if nobody is reading
+	for i := 0; i < 3; i++ {
+		stream.Publish(cosmos.Event{Type: cosmos.MoveEvent, Round: i})
+	}
+	e, ok := <-slow
+	assert.True(t, ok)
+	assert.Equal(t, 0, e.Round)
+	_, ok = <-slow
+	assert.False(t, ok, "slow subscriber should be dropped")
+	assert.True(t, slowSub.Dropped())
+	assert.False(t, fastSub.Dropped())
+	for i := 0; i < 3; i++ {
+		e = <-fast
+		assert.Equal(t, i, e.Round)
+	}
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// testSeed is the seed of the scenarios that do not set one, so their
+// results are the same on every run
+const testSeed = 1
+
+// testCmd runs scenarios and checks their expectations
+var testCmd = &cobra.Command{
+	Use:   "test <scenario or directory>...",
+	Short: "Run scenarios and check their expected outcomes",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		failed, err := CheckScenarios(os.Stdout, args)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(testCmd)
+}
+
+// CheckScenarios runs every scenario in the given files and directories,
+// reports whether each one met its expectations and returns how many did not
+func CheckScenarios(w io.Writer, paths []string) (int, error) {
+	files, err := scenarioFiles(paths)
+	if err != nil {
+		return 0, err
+	}
+	failed := 0
+	for _, filename := range files {
+		sc, err := LoadScenario(filename)
+		var failures []string
+		if err == nil {
+			if sc.Seed == 0 {
+				sc.Seed = testSeed
+			}
+			var outcome Outcome
+			outcome, err = sc.Run(io.Discard, QuietOutput)
+			if err == nil {
+				failures = sc.Expect.Check(outcome)
+			}
+		}
+		if err != nil {
+			failures = append(failures, err.Error())
+		}
+		if len(failures) == 0 {
+			fmt.Fprintln(w, "PASS "+filename)
+			continue
+		}
+		failed++
+		fmt.Fprintln(w, "FAIL "+filename)
+		for _, failure := range failures {
+			fmt.Fprintln(w, "    "+failure)
+		}
+	}
+	fmt.Fprintf(w, "%v passed, %v failed\n", len(files)-failed, failed)
+	return failed, nil
+}
+
+// scenarioFiles lists the scenarios in the paths, looking for .yaml, .yml
+// and .json files inside directories
+func scenarioFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(filename string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			switch strings.ToLower(filepath.Ext(filename)) {
+			case ".yaml", ".yml", ".json":
+				if !d.IsDir() {
+					files = append(files, filename)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckScenarios(t *testing.T) {
+	var buf bytes.Buffer
+	failed, err := CheckScenarios(&buf, []string{"../scenarios"})
+	require.Nil(t, err)
+	assert.Equal(t, 0, failed, buf.String())
+	assert.Contains(t, buf.String(), "PASS ../scenarios/duel.yaml\n")
+	assert.Contains(t, buf.String(), "3 passed, 0 failed\n")
+}
+
+func TestCheckScenariosFailure(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "wrong.yaml", `
+map: Foo east=Bar
+aliens: [Foo, Bar]
+strategy: first
+expect:
+  survive: [Bar]
+  alive: [0]
+`)
+	writeFile(t, dir, "broken.yaml", "map: Foo up=Bar\n")
+	writeFile(t, dir, "notes.txt", "not a scenario\n")
+	var buf bytes.Buffer
+	failed, err := CheckScenarios(&buf, []string{dir})
+	require.Nil(t, err)
+	assert.Equal(t, 2, failed)
+	assert.Contains(t, buf.String(), "wrong.yaml\n    expected Bar to survive\n    expected alien 0 to be alive\n")
+	assert.Contains(t, buf.String(), "0 passed, 2 failed\n")
+
+	_, err = CheckScenarios(&buf, []string{dir + "/missing"})
+	assert.Error(t, err)
+}
@@ -1,160 +1,303 @@
 package cmd
 
 import (
-	"bufio"
 	"fmt"
+	"io"
 	"math/rand"
 	"os"
-	"path/filepath"
-	"strconv"
 	"strings"
 
 	"github.com/fedekunze/alien_task/cosmos"
 )
 
-// Init initializes the battle of aliens according to the provided arguments
-// in the CLI
-func Init(filename string, totalAliens int) error {
-	var m = cosmos.CreateMap()
-	fmt.Println("Reading file...")
-	fmt.Println()
-	err := ReadMap(filename, m)
+// Init initializes the battle of aliens according to the configuration
+// given in the CLI and prints its results on stdout
+func Init(cfg Config) error {
+	return RunMap(os.Stdout, cfg)
+}
+
+// RunMap runs the battle of aliens described by the configuration and prints
+// its results to w
+func RunMap(w io.Writer, cfg Config) error {
+	err := cfg.Validate()
 	if err != nil {
 		return err
 	}
-	fmt.Println("Placing aliens in cities...")
-	// select a random city from the set to add one
-	for index := 0; index < totalAliens; index++ {
-		randCity := rand.Intn(m.CitiesLen())
-		cityName := m.CitiesIDName[randCity]
-		city, er := m.GetCity(cityName)
-		if er != nil {
-			return nil
-		}
-		alien := cosmos.NewAlien(index, city)
-		city.AddAlien(alien)
-		m.Aliens.Set(index, alien)
+	m, err := createMap(cfg.Directions)
+	if err != nil {
+		return err
 	}
-	fmt.Println("Running simulation...")
-	aliensLeft, round, err := cosmos.Simulate(m, totalAliens)
+	logger.Info("Reading file...", "file", cfg.File)
+	err = ReadMap(cfg.File, m)
 	if err != nil {
 		return err
 	}
-	fmt.Println()
-	fmt.Println("SIMULATION ENDED AT ROUND " + strconv.Itoa(round))
-	fmt.Println("Aliens left : " + strconv.Itoa(aliensLeft) + ". Printing results:")
-	fmt.Println()
-	PrettyPrint(m)
-	fmt.Println()
-	return nil
+	logger.Info("Placing aliens in cities...", "aliens", cfg.Aliens, "cities", m.CitiesLen())
+	sim, err := NewBattle(m, cfg.Aliens, cfg.Rules())
+	if err != nil {
+		return err
+	}
+	logger.Info("Running simulation...", "seed", sim.Seed())
+	// checkpoints replay the run with the seed that was picked
+	cfg.Seed = sim.Seed()
+	return runBattle(w, m, sim, checkpointHeader{Config: cfg})
 }
 
-// ParseLine parses each line from the file and creates a city
-func ParseLine(line string, m *cosmos.Map) error {
-	line = strings.TrimSpace(line)
-	words := strings.Split(line, " ")
-	city, err := m.GetCity(words[0])
-	if err != nil {
-		city = cosmos.NewCity(words[0])
-		m.SetCity(city)
-		nCities := len(m.CitiesIDName)
-		m.CitiesIDName[nCities] = words[0]
+// runBattle runs a battle from the start or from a checkpoint, saving the
+// checkpoints of the configuration, prints its results to w and records the
+// run in the history
+func runBattle(w io.Writer, m *cosmos.Map, sim *cosmos.Simulation, state checkpointHeader) error {
+	cfg := state.Config
+	var recorder *runRecorder
+	if cfg.History != "" {
+		rec := configRecord(cfg)
+		rec.MapHash = state.MapHash
+		rec.Destroyed = append([]string{}, state.Destroyed...)
+		recorder = newRunRecorder(m, sim, rec)
+		// checkpoints keep the hash of the map before the battle
+		state.MapHash = recorder.rec.MapHash
 	}
-	for i := 1; i < len(words); i++ {
-		word := strings.TrimSpace(words[i])
-		path := strings.Split(word, "=")
-		dir, err := cosmos.StrToDir(strings.TrimSpace(path[0]))
+	report := NewReport(w, OutputMode(cfg.Output))
+	report.destroyed = state.Destroyed
+	sim.Subscribe(report.Listen)
+	var events *EventLog
+	if cfg.Events != "" {
+		f, err := openEventLog(cfg.Events, state.Events)
 		if err != nil {
 			return err
 		}
-		// check if city with name == path[1] exists
-		cityName := strings.TrimSpace(path[1])
-		destCity, err := m.GetCity(cityName)
-		// Create city if it does not exist already
-		if err != nil {
-			destCity = cosmos.NewCity(cityName)
-			m.SetCity(destCity)
-			nCities := len(m.CitiesIDName)
-			m.CitiesIDName[nCities] = cityName
-		}
-		// Add road from origin city
-		road := cosmos.NewRoad(city, dir, destCity)
-		err = city.AddRoad(road)
+		defer f.Close()
+		events = NewEventLog(f)
+		sim.Subscribe(events.Listen)
+	}
+	if cfg.CheckpointEvery > 0 {
+		err := os.MkdirAll(cfg.CheckpointDir, 0755)
 		if err != nil {
 			return err
 		}
-		// Add opossite direction road from destination city
-		road = cosmos.NewRoad(destCity, road.OppositeDirection(), city)
-		err = destCity.AddRoad(road)
+		sim.Checkpoints(cfg.CheckpointEvery, func(round int) error {
+			header := checkpointHeader{Config: cfg, Destroyed: report.destroyed, MapHash: state.MapHash}
+			if events != nil {
+				header.Events = state.Events + events.Written()
+			}
+			filename := checkpointFile(cfg.CheckpointDir, round)
+			logger.Info("Saving checkpoint...", "file", filename, "round", round)
+			return saveCheckpoint(filename, header, sim)
+		})
+	}
+	aliensLeft, round, err := sim.Run()
+	recordRun(cfg.History, recorder, sim, err)
+	if err != nil {
+		return err
+	}
+	report.Print(m, aliensLeft, round)
+	if events != nil {
+		return events.Err()
+	}
+	return nil
+}
+
+// NewBattle places the aliens in random cities of the map and creates the
+// simulation that runs them with the given rules. The seed of the rules
+// drives both the placement and the moves, from separate streams.
+func NewBattle(m *cosmos.Map, totalAliens int, rules cosmos.Config) (*cosmos.Simulation, error) {
+	sim := cosmos.NewSimulation(m, totalAliens)
+	err := sim.SetConfig(rules)
+	if err != nil {
+		return nil, err
+	}
+	sim.SetLogger(logger)
+	err = PlaceAliens(m, totalAliens, placementRNG(sim.Seed()))
+	if err != nil {
+		return nil, err
+	}
+	return sim, nil
+}
+
+// placementSalt mixes the seed of a simulation into the seed of its
+// placement, so the placement and the moves don't draw the same numbers
+const placementSalt = 0x5bd1e9955bd1e995
+
+// placementRNG creates the random numbers that place the aliens of a
+// simulation with the given seed
+func placementRNG(seed int64) *rand.Rand {
+	return rand.New(rand.NewSource(seed ^ placementSalt))
+}
+
+// PlaceAliens places the given number of aliens in random cities of the map
+func PlaceAliens(m *cosmos.Map, totalAliens int, rng *rand.Rand) error {
+	if m.CitiesLen() == 0 {
+		return fmt.Errorf("Map has no cities to place aliens")
+	}
+	// select a random city from the set to add one
+	for index := 0; index < totalAliens; index++ {
+		randCity := rng.Intn(m.CitiesLen())
+		city, err := m.GetCity(m.CityName(randCity))
 		if err != nil {
 			return err
 		}
+		alien := cosmos.NewAlien(index, city)
+		city.AddAlien(alien)
+		m.Aliens.Set(index, alien)
 	}
 	return nil
 }
 
-// ReadMap reads a map from a .txt file
-func ReadMap(filename string, m *cosmos.Map) error {
-	// Check if file is txt
-	var extension = filepath.Ext(filename)
-	if !strings.Contains(extension, ".txt") {
-		return fmt.Errorf("File %v does not have .txt format", filename)
-	}
-	// Get filename from absolute path
-	var err error
-	fmt.Println(filename)
-	if !filepath.IsAbs(filename) {
-		filename, err = filepath.Abs(filename)
+// ParseLine parses each line from the file and creates a city. Every road is
+// added in both directions (north=Bar) unless it is one-way (north->Bar), so
+// a line can't declare a road that conflicts with the roads already in the
+// map. Roads can only take the directions of the map.
+func ParseLine(line string, m *cosmos.Map) error {
+	words := strings.Fields(line)
+	if len(words) == 0 {
+		return nil // skip empty lines
+	}
+	city := getOrCreateCity(words[0], m)
+	for _, word := range words[1:] {
+		path, oneWay := splitRoad(word)
+		if len(path) != 2 || path[1] == "" {
+			return fmt.Errorf("Invalid road %v from city %v", word, city.Name())
+		}
+		dir, err := m.Directions().Parse(path[0])
 		if err != nil {
 			return err
 		}
+		cityName := path[1]
+		if cityName == city.Name() {
+			return fmt.Errorf("City %v can't have a road to itself", cityName)
+		}
+		// Create destination city if it does not exist already
+		destCity := getOrCreateCity(cityName, m)
+		if oneWay {
+			err = addOneWayRoad(cosmos.NewOneWayRoad(city, dir, destCity))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		road := cosmos.NewRoad(city, dir, destCity)
+		reverse := cosmos.NewRoad(destCity, road.OppositeDirection(), city)
+		// Check both roads before adding any, so the map never ends up
+		// with a road in a single direction
+		for _, r := range []*cosmos.Road{road, reverse} {
+			err = checkRoad(r)
+			if err != nil {
+				return err
+			}
+		}
+		for _, r := range []*cosmos.Road{road, reverse} {
+			err = r.Origin().AddRoad(r)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// createMap creates an empty map whose roads take the named set of
+// directions
+func createMap(directions string) (*cosmos.Map, error) {
+	set, err := cosmos.DirectionSetByName(directions)
+	if err != nil {
+		return nil, err
+	}
+	m := cosmos.CreateMap()
+	m.SetDirections(set)
+	return m, nil
+}
+
+// splitRoad splits a road in its direction and its destination, which follows
+// = for a two-way road and -> for a one-way road, whichever comes first
+func splitRoad(word string) ([]string, bool) {
+	eq := strings.Index(word, "=")
+	arrow := strings.Index(word, "->")
+	if arrow >= 0 && (eq < 0 || arrow < eq) {
+		return []string{word[:arrow], word[arrow+2:]}, true
 	}
-	file, err := os.Open(filename)
+	return strings.SplitN(word, "=", 2), false
+}
+
+// addOneWayRoad adds a one-way road to its origin, unless it was already
+// declared
+func addOneWayRoad(road *cosmos.Road) error {
+	err := checkRoad(road)
 	if err != nil {
 		return err
 	}
-	defer file.Close() // closes file on return
+	existing, _ := road.Origin().GetRoad(road.GetDirection().IntValue())
+	if existing != nil {
+		return nil
+	}
+	return road.Origin().AddRoad(road)
+}
 
-	scanner := bufio.NewScanner(file)
-	scanner.Split(bufio.ScanLines)
+// getOrCreateCity returns the city with the given name, adding it to the map
+// if it does not exist
+func getOrCreateCity(name string, m *cosmos.Map) *cosmos.City {
+	city, err := m.GetCity(name)
+	if err != nil {
+		city = cosmos.NewCity(name)
+		m.SetCity(city)
+	}
+	return city
+}
 
-	// This is our buffer now
-	var line string
-	for scanner.Scan() {
-		line = scanner.Text()
-		fmt.Println(line)
-		err = ParseLine(line, m)
-		if err != nil {
-			return err
-		}
+// checkRoad checks that the origin of the road has no other road in the same
+// direction, nor the same road the other way
+func checkRoad(road *cosmos.Road) error {
+	existing, err := road.Origin().GetRoad(road.GetDirection().IntValue())
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.Destination() != road.Destination() {
+		return fmt.Errorf("City %v has roads %v to both %v and %v", road.Origin().Name(),
+			road.GetDirection(), existing.Destination().Name(), road.Destination().Name())
+	}
+	if existing != nil && existing.IsOneWay() != road.IsOneWay() {
+		return fmt.Errorf("City %v has a one-way and a two-way road %v to %v", road.Origin().Name(),
+			road.GetDirection(), road.Destination().Name())
 	}
-	fmt.Println()
 	return nil
 }
 
+// ReadMap reads a map from a .txt file, a gzip-compressed .txt.gz file or, if
+// the filename is -, the standard input
+func ReadMap(filename string, m *cosmos.Map) error {
+	input, err := OpenMap(filename)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+	return ParseMap(input, m)
+}
+
+// ParseMap reads a map in the .txt format from any reader, one line at a
+// time, so lines can be of any length. The lines go straight into the graph
+// of the map, without a string for each name, and the lines added to a map
+// that has cities are checked against its roads, like with ParseLine.
+func ParseMap(r io.Reader, m *cosmos.Map) error {
+	return m.Parse(r)
+}
+
 // ConcatRoads concatenates roads into the desired output format for printing results
 func ConcatRoads(road *cosmos.Road, line string) string {
 	var direction = road.GetDirection()
 	var destination = road.Destination().Name()
 	strValue, _ := direction.Value()
+	if road.IsOneWay() {
+		return line + " " + strValue + "->" + destination
+	}
 	return line + " " + strValue + "=" + destination
 }
 
 // PrettyPrint prints the state of the cosmos
 func PrettyPrint(m *cosmos.Map) {
-	for i := 0; i < m.CitiesLen(); i++ {
-		newline := m.CitiesIDName[i]
-		city, _ := m.GetCity(newline)
-		if !city.IsDestroyed() {
-			for dir := 0; dir < 4; dir++ {
-				road, _ := city.GetRoad(dir)
-				if road != nil {
-					if road.IsAvailable() {
-						newline = ConcatRoads(road, newline)
-					}
-				}
-			}
-			fmt.Println(newline)
-		}
-	}
+	WriteMap(os.Stdout, m)
+}
+
+// WriteMap writes the cities that were not destroyed and their available
+// roads in the .txt format
+func WriteMap(w io.Writer, m *cosmos.Map) {
+	m.WriteTo(w)
 }
@@ -0,0 +1,285 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/fedekunze/alien_task/cosmos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLine(t *testing.T) {
+	m := cosmos.CreateMap()
+	require.Nil(t, ParseLine("  Foo \tnorth=Bar  west=Ba=z ", m))
+	require.Nil(t, ParseLine("", m))
+	require.Nil(t, ParseLine("Bar south=Foo", m)) // same road, declared from the other side
+	assert.Equal(t, 3, m.CitiesLen())
+	baz, err := m.GetCity("Ba=z")
+	require.Nil(t, err)
+	road, err := baz.GetRoad(2)
+	require.Nil(t, err)
+	assert.Equal(t, "Foo", road.Destination().Name())
+
+	invalid := []string{
+		"Foo north",     // no destination
+		"Foo north=",    // empty destination
+		"Foo =Bar",      // no direction
+		"Foo up=Bar",    // unknown direction
+		"Foo east=Foo",  // road to itself
+		"Foo north=Qux", // Foo already goes north to Bar
+		"Qux south=Foo", // the road back would go north from Foo too
+		"Ba=z east=Qux", // Ba=z already goes east to Foo
+	}
+	for _, line := range invalid {
+		assert.Error(t, ParseLine(line, m), line)
+	}
+}
+
+func TestParseMapMoreLines(t *testing.T) {
+	m := cosmos.CreateMap()
+	require.Nil(t, ParseMap(strings.NewReader("Foo north=Bar\n"), m))
+	// the roads of a map that has cities are checked against its roads
+	assert.EqualError(t, ParseMap(strings.NewReader("Bar south=Baz\n"), m), "Line 1: City Bar has roads south to both Foo and Baz")
+	require.Nil(t, ParseMap(strings.NewReader("Baz west=Foo\n"), m))
+	checkReverseRoads(t, m)
+	var buf bytes.Buffer
+	WriteMap(&buf, m)
+	assert.Equal(t, "Foo north=Bar east=Baz\nBar south=Foo\nBaz west=Foo\n", buf.String())
+}
+
+func TestParseMapDirections(t *testing.T) {
+	text := "Foo northeast=Bar north=Baz\nBar northwest=Baz\n"
+	m := cosmos.CreateMap()
+	assert.EqualError(t, ParseMap(strings.NewReader(text), m), "Line 1: Direction northeast is not in direction set 4")
+
+	m, err := createMap("hex")
+	require.Nil(t, err)
+	require.Nil(t, ParseMap(strings.NewReader(text), m))
+	checkReverseRoads(t, m)
+	bar, err := m.GetCity("Bar")
+	require.Nil(t, err)
+	road, err := bar.GetRoad(cosmos.Southwest.IntValue())
+	require.Nil(t, err)
+	assert.Equal(t, "Foo", road.Destination().Name())
+	var buf bytes.Buffer
+	WriteMap(&buf, m)
+	assert.Equal(t, "Foo north=Baz northeast=Bar\nBar southwest=Foo northwest=Baz\nBaz south=Foo southeast=Bar\n", buf.String())
+
+	// the graph loader reads the same map
+	g, err := cosmos.LoadGraphDirections(strings.NewReader(text), cosmos.Hex)
+	require.Nil(t, err)
+	var graphBuf bytes.Buffer
+	g.WriteTo(&graphBuf)
+	assert.Equal(t, buf.String(), graphBuf.String())
+
+	assert.EqualError(t, ParseMap(strings.NewReader("Foo east=Bar"), m), "Line 1: Direction east is not in direction set hex")
+	_, err = createMap("octagonal")
+	assert.Error(t, err)
+}
+
+func TestRunMapDirections(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{
+		File:           writeFile(t, dir, "layers.txt", "Foo up=Bar northeast=Baz\nBar northeast=Qux\nBaz up=Qux\n"),
+		Directions:     "3d",
+		Aliens:         4,
+		Seed:           3,
+		Strategy:       "random",
+		FightThreshold: 2,
+		MaxRounds:      100,
+		Output:         string(SummaryOutput),
+	}
+	var out bytes.Buffer
+	assert.EqualError(t, RunMap(&out, cfg), "Line 1: Direction northeast is not in direction set 3d")
+	cfg.Directions = "4"
+	assert.Error(t, RunMap(&out, cfg))
+	cfg.Directions = "octagonal"
+	assert.EqualError(t, cfg.Validate(), "octagonal is not a valid direction set, use 4, 8, hex or 3d")
+
+	cfg.Directions = "8"
+	cfg.File = writeFile(t, dir, "diagonals.txt", "Foo east=Bar northeast=Baz\nBar north=Baz\n")
+	require.Nil(t, RunMap(&out, cfg))
+	assert.Contains(t, out.String(), "aliens_left=")
+}
+
+func TestParseOneWayRoads(t *testing.T) {
+	// a river flows east from the Lake to the Sea, and a bridge crosses it
+	text := "Lake east->Mill\nMill east->Sea north=Bridge\nSea west->Bridge\n"
+	m := cosmos.CreateMap()
+	require.Nil(t, ParseMap(strings.NewReader(text), m))
+	checkReverseRoads(t, m)
+	mill, err := m.GetCity("Mill")
+	require.Nil(t, err)
+	road, err := mill.GetRoad(cosmos.East.IntValue())
+	require.Nil(t, err)
+	assert.True(t, road.IsOneWay())
+	road, err = mill.GetRoad(cosmos.West.IntValue())
+	require.Nil(t, err)
+	assert.Nil(t, road)
+	road, err = mill.GetRoad(cosmos.North.IntValue())
+	require.Nil(t, err)
+	assert.False(t, road.IsOneWay())
+	assert.Len(t, mill.GetIncoming(), 1)
+
+	var buf bytes.Buffer
+	WriteMap(&buf, m)
+	assert.Equal(t, "Lake east->Mill\nMill north=Bridge east->Sea\nSea west->Bridge\nBridge south=Mill\n", buf.String())
+	g, err := cosmos.LoadGraph(strings.NewReader(text))
+	require.Nil(t, err)
+	var graphBuf bytes.Buffer
+	g.WriteTo(&graphBuf)
+	assert.Equal(t, buf.String(), graphBuf.String())
+
+	// the same one-way road can be declared twice, but not as two-way too
+	for input, msg := range map[string]string{
+		"Foo east->Bar\nFoo east->Bar": "",
+		"Foo east->Bar\nBar west->Foo": "",
+		"Foo east->Bar\nFoo east=Bar":  "Line 2: City Foo has a one-way and a two-way road east to Bar",
+		"Foo east->Bar\nBar west=Foo":  "Line 2: City Foo has a one-way and a two-way road east to Bar",
+		"Foo east=Bar\nFoo east->Bar":  "Line 2: City Foo has a one-way and a two-way road east to Bar",
+		"Foo east->Bar east=Baz":       "Line 1: City Foo has roads east to both Bar and Baz",
+		"Foo east->":                   "Line 1: Invalid road east-> from city Foo",
+	} {
+		err := parseLines(strings.NewReader(input), cosmos.CreateMap())
+		_, graphErr := cosmos.LoadGraph(strings.NewReader(input))
+		if msg == "" {
+			assert.Nil(t, err, input)
+			assert.Nil(t, graphErr, input)
+			continue
+		}
+		assert.EqualError(t, err, msg, input)
+		assert.EqualError(t, graphErr, msg, input)
+	}
+}
+
+// FuzzParseMap checks that any input either fails to parse or produces a
+// map where every two-way road has its reverse road, and that writing the
+// map and parsing it again produces the same map
+func FuzzParseMap(f *testing.F) {
+	f.Add("Foo north=Bar west=Baz south=Qu-ux\nBar south=Foo west=Bee\n")
+	f.Fuzz(func(t *testing.T, input string) {
+		m := cosmos.CreateMap()
+		err := parseLines(strings.NewReader(input), m)
+		// the graph loader follows the same rules as the parser
+		g, graphErr := cosmos.LoadGraph(strings.NewReader(input))
+		if err != nil {
+			require.NotNil(t, graphErr, "graph loader accepts a map the parser rejects: %v", err)
+			assert.Equal(t, err.Error(), graphErr.Error())
+			return
+		}
+		require.Nil(t, graphErr)
+		checkReverseRoads(t, m)
+		var buf bytes.Buffer
+		WriteMap(&buf, m)
+		var graphBuf bytes.Buffer
+		g.WriteTo(&graphBuf)
+		assert.Equal(t, buf.String(), graphBuf.String())
+		assert.Equal(t, roadsByCity(m), roadsByCity(g.Map()))
+		other := cosmos.CreateMap()
+		require.Nil(t, ParseMap(&buf, other), "output of the writer doesn't parse:\n%v", buf.String())
+		assert.Equal(t, roadsByCity(m), roadsByCity(other))
+	})
+}
+
+// parseLines adds the cities and roads of every line to the map with
+// ParseLine, so the checks of the city API can be compared with the loader
+func parseLines(r io.Reader, m *cosmos.Map) error {
+	return cosmos.ReadLines(r, func(line []byte) error {
+		return ParseLine(string(line), m)
+	})
+}
+
+// checkReverseRoads checks that every two-way road leads to a city with a
+// road back
+func checkReverseRoads(t *testing.T, m *cosmos.Map) {
+	for i := 0; i < m.CitiesLen(); i++ {
+		city, err := m.GetCity(m.CityName(i))
+		require.Nil(t, err)
+		for _, road := range city.GetRoads() {
+			if road == nil || road.IsOneWay() {
+				continue
+			}
+			reverse, _ := road.Destination().GetRoad(road.OppositeDirection().IntValue())
+			require.NotNil(t, reverse, "road %v from %v has no reverse", road.GetDirection(), city.Name())
+			assert.Equal(t, city, reverse.Destination())
+		}
+	}
+}
+
+// roadsByCity describes a map as the destination of each road of each city,
+// after -> if the road is one-way
+func roadsByCity(m *cosmos.Map) map[string][4]string {
+	roads := make(map[string][4]string)
+	for i := 0; i < m.CitiesLen(); i++ {
+		city, _ := m.GetCity(m.CityName(i))
+		var dests [4]string
+		for dir := 0; dir < 4; dir++ {
+			road, _ := city.GetRoad(dir)
+			if road != nil && road.IsOneWay() {
+				dests[dir] = "->" + road.Destination().Name()
+			} else if road != nil {
+				dests[dir] = road.Destination().Name()
+			}
+		}
+		roads[city.Name()] = dests
+	}
+	return roads
+}
+
+// gridText writes a square grid with the given number of cities in the .txt
+// format
+func gridText(cities int) string {
+	side := 1
+	for side*side < cities {
+		side++
+	}
+	var text strings.Builder
+	for y := 0; y < side; y++ {
+		for x := 0; x < side; x++ {
+			fmt.Fprintf(&text, "%v-%v", x, y)
+			if x+1 < side {
+				fmt.Fprintf(&text, " east=%v-%v", x+1, y)
+			}
+			if y+1 < side {
+				fmt.Fprintf(&text, " south=%v-%v", x, y+1)
+			}
+			text.WriteByte('\n')
+		}
+	}
+	return text.String()
+}
+
+func BenchmarkLoadMap(b *testing.B) {
+	text := gridText(1000000)
+	b.Run("lines", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			err := parseLines(strings.NewReader(text), cosmos.CreateMap())
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("map", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			err := ParseMap(strings.NewReader(text), cosmos.CreateMap())
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("graph", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, err := cosmos.LoadGraph(strings.NewReader(text))
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
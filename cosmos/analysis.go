@@ -0,0 +1,296 @@
+package cosmos
+
+import "sort"
+
+// ========== Analysis ==========
+
+// exactDiameterCities is the largest map whose diameter is computed exactly,
+// with a search from every city. Bigger maps get a lower bound.
+var exactDiameterCities = 4096
+
+// Analysis describes the structure of the cities that were not destroyed and
+// their available roads
+type Analysis struct {
+	Cities             int         `json:"cities"`
+	Roads              int         `json:"roads"`
+	Degrees            []int       `json:"degrees"`             // number of cities with each number of roads
+	Components         []int       `json:"components"`          // sizes of the connected components, largest first
+	ArticulationPoints []string    `json:"articulation_points"` // cities whose destruction splits their component
+	Bridges            [][2]string `json:"bridges"`             // roads whose destruction splits their component
+	Diameter           int         `json:"diameter"`            // most roads between two connected cities
+	DiameterExact      bool        `json:"diameter_exact"`      // false if the diameter is a lower bound
+	Isolated           []string    `json:"isolated"`            // cities with no roads
+}
+
+// Analyze describes the structure of the map. A two-way road is counted once,
+// and one-way roads link their cities like the others: the components are the
+// weakly connected ones and the diameter ignores the direction of the roads.
+func Analyze(m *Map) Analysis {
+	n := newNetwork(m, true)
+	a := Analysis{
+		Cities:             len(n.cities),
+		Roads:              n.links,
+		Degrees:            []int{},
+		Components:         []int{},
+		ArticulationPoints: []string{},
+		Bridges:            [][2]string{},
+		Isolated:           []string{},
+	}
+	for id := range n.cities {
+		degree := n.degree(id)
+		for len(a.Degrees) <= degree {
+			a.Degrees = append(a.Degrees, 0)
+		}
+		a.Degrees[degree]++
+		if degree == 0 {
+			a.Isolated = append(a.Isolated, n.name(int32(id)))
+		}
+	}
+	components := n.components()
+	for _, component := range components {
+		a.Components = append(a.Components, len(component))
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(a.Components)))
+	a.ArticulationPoints, a.Bridges = n.cuts()
+	a.Diameter, a.DiameterExact = n.diameter(components)
+	sort.Strings(a.Isolated)
+	return a
+}
+
+// ----- Unexported functions -----
+
+// network is the graph of the cities that were not destroyed and their
+// available roads, with the cities numbered in the order of their ids and the
+// roads of each city in a single array
+type network struct {
+	graph     *Graph
+	cities    []int32 // id in the graph of each city
+	ids       []int32 // number of each city of the graph in the network, -1 if destroyed
+	offsets   []int32 // the roads of city i are neighbors[offsets[i]:offsets[i+1]]
+	neighbors []int32 // destination of each road
+	roads     []int32 // id of each road, shared with its reverse road
+	links     int     // roads between the cities, with a two-way road counted once
+}
+
+// newNetwork builds the network of the map. An undirected network also takes
+// the one-way roads the other way.
+func newNetwork(m *Map, undirected bool) *network {
+	g := m.graph
+	n := &network{graph: g, ids: make([]int32, g.CitiesLen())}
+	for id := range n.ids {
+		n.ids[id] = -1
+		if !g.IsDestroyed(id) {
+			n.ids[id] = int32(len(n.cities))
+			n.cities = append(n.cities, int32(id))
+		}
+	}
+	var incoming [][]int32 // slots of the one-way roads into each city
+	if undirected {
+		incoming = make([][]int32, len(n.cities))
+		for i, to := range g.roads {
+			if to >= 0 && g.isOneWay(i) && g.available(i) {
+				incoming[n.ids[to]] = append(incoming[n.ids[to]], int32(i))
+			}
+		}
+	}
+	n.offsets = make([]int32, 0, len(n.cities)+1)
+	for id, city := range n.cities {
+		n.offsets = append(n.offsets, int32(len(n.neighbors)))
+		for slot := 0; slot < g.slots; slot++ {
+			dest, ok := g.roadAt(int(city), slot)
+			if !ok {
+				continue
+			}
+			to := n.ids[dest]
+			n.neighbors = append(n.neighbors, to)
+			// a road and its reverse share the id of the one in the lowest slot
+			roadID := int32(len(compass)*id + slot)
+			if reverse, _ := g.roadAt(dest, slot^1); !g.isOneWay(g.slots*int(city)+slot) && reverse == int(city) {
+				roadID = min(roadID, int32(len(compass))*to+int32(slot^1))
+			}
+			n.roads = append(n.roads, roadID)
+			if roadID == int32(len(compass)*id+slot) {
+				n.links++
+			}
+		}
+		if !undirected {
+			continue
+		}
+		for _, i := range incoming[id] {
+			from := n.ids[int(i)/g.slots]
+			n.neighbors = append(n.neighbors, from)
+			n.roads = append(n.roads, int32(len(compass))*from+i%int32(g.slots))
+		}
+	}
+	n.offsets = append(n.offsets, int32(len(n.neighbors)))
+	return n
+}
+
+// name returns the name of a city of the network
+func (n *network) name(id int32) string {
+	return n.graph.CityName(int(n.cities[id]))
+}
+
+// degree returns the number of roads of a city
+func (n *network) degree(id int) int {
+	return int(n.offsets[id+1] - n.offsets[id])
+}
+
+// bfs fills dist with the roads from the city to every city it reaches, -1
+// for the others, and prev, if any, with the city each one was reached from.
+// It returns the cities it reached in the order it did.
+func (n *network) bfs(from int, dist []int32, prev []int32, queue []int32) []int32 {
+	for i := range dist {
+		dist[i] = -1
+	}
+	dist[from] = 0
+	queue = append(queue[:0], int32(from))
+	for head := 0; head < len(queue); head++ {
+		city := queue[head]
+		for _, to := range n.neighbors[n.offsets[city]:n.offsets[city+1]] {
+			if dist[to] < 0 {
+				dist[to] = dist[city] + 1
+				if prev != nil {
+					prev[to] = city
+				}
+				queue = append(queue, to)
+			}
+		}
+	}
+	return queue
+}
+
+// components returns the cities of each connected component
+func (n *network) components() [][]int32 {
+	var components [][]int32
+	dist := make([]int32, len(n.cities))
+	seen := make([]bool, len(n.cities))
+	var queue []int32
+	for id := range n.cities {
+		if seen[id] {
+			continue
+		}
+		queue = n.bfs(id, dist, nil, queue)
+		component := append([]int32(nil), queue...)
+		for _, city := range component {
+			seen[city] = true
+		}
+		components = append(components, component)
+	}
+	return components
+}
+
+// diameter returns the most roads between two connected cities, and whether
+// it is exact. Big maps get the lower bound of two searches per component:
+// one from any city and another from the farthest city from it.
+func (n *network) diameter(components [][]int32) (int, bool) {
+	dist := make([]int32, len(n.cities))
+	var queue []int32
+	exact := len(n.cities) <= exactDiameterCities
+	diameter := 0
+	for _, component := range components {
+		sources := component
+		if !exact {
+			queue = n.bfs(int(component[0]), dist, nil, queue)
+			sources = []int32{queue[len(queue)-1]}
+		}
+		for _, from := range sources {
+			queue = n.bfs(int(from), dist, nil, queue)
+			diameter = max(diameter, int(dist[queue[len(queue)-1]]))
+		}
+	}
+	return diameter, exact
+}
+
+// cuts returns the articulation points and the bridges of the network, with
+// an iterative depth-first search so big maps don't overflow the stack
+func (n *network) cuts() ([]string, [][2]string) {
+	order := make([]int32, len(n.cities)) // order of the visit, 0 if not visited
+	low := make([]int32, len(n.cities))   // lowest order reachable from the subtree
+	type frame struct {
+		city     int32
+		parent   int32 // road to the parent, -1 for the root
+		next     int32 // next road to follow
+		children int
+	}
+	var stack []frame
+	var points []string
+	var bridges [][2]string
+	visited := int32(0)
+	for root := range n.cities {
+		if order[root] != 0 {
+			continue
+		}
+		visited++
+		order[root], low[root] = visited, visited
+		stack = append(stack[:0], frame{city: int32(root), parent: -1, next: n.offsets[root]})
+		for len(stack) > 0 {
+			top := &stack[len(stack)-1]
+			city := top.city
+			if top.next < n.offsets[city+1] {
+				i := top.next
+				top.next++
+				to := n.neighbors[i]
+				if n.roads[i] == top.parent {
+					continue
+				}
+				if order[to] != 0 {
+					low[city] = min(low[city], order[to])
+					continue
+				}
+				top.children++
+				visited++
+				order[to], low[to] = visited, visited
+				stack = append(stack, frame{city: to, parent: n.roads[i], next: n.offsets[to]})
+				continue
+			}
+			// every road of the city was followed, go back to its parent
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				if top.children > 1 {
+					points = append(points, n.name(city))
+				}
+				continue
+			}
+			parent := &stack[len(stack)-1]
+			low[parent.city] = min(low[parent.city], low[city])
+			if low[city] > order[parent.city] {
+				bridge := [2]string{n.name(parent.city), n.name(city)}
+				if bridge[0] > bridge[1] {
+					bridge[0], bridge[1] = bridge[1], bridge[0]
+				}
+				bridges = append(bridges, bridge)
+			}
+			if len(stack) > 1 && low[city] >= order[parent.city] {
+				points = append(points, n.name(parent.city))
+			}
+		}
+	}
+	// a city is found once for each of its components split by it
+	sort.Strings(points)
+	points = compactStrings(points)
+	sort.Slice(bridges, func(i, j int) bool {
+		if bridges[i][0] != bridges[j][0] {
+			return bridges[i][0] < bridges[j][0]
+		}
+		return bridges[i][1] < bridges[j][1]
+	})
+	if points == nil {
+		points = []string{}
+	}
+	if bridges == nil {
+		bridges = [][2]string{}
+	}
+	return points, bridges
+}
+
+// compactStrings removes the repeated strings of a sorted list
+func compactStrings(list []string) []string {
+	out := list[:0]
+	for i, s := range list {
+		if i == 0 || s != list[i-1] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
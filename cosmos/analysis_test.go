@@ -0,0 +1,79 @@
+package cosmos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyze(t *testing.T) {
+	// a cycle Foo-Bar-Qux-Baz with a tail Qux-Zed, an island and a loose city
+	m := textMap(t, "Foo north=Bar east=Baz\nBar east=Qux\nBaz south=Qux\nQux east=Zed\nBee west=Ann\nOne\n")
+	a := Analyze(m)
+	assert.Equal(t, 8, a.Cities)
+	assert.Equal(t, 6, a.Roads)
+	assert.Equal(t, []int{1, 3, 3, 1}, a.Degrees)
+	assert.Equal(t, []int{5, 2, 1}, a.Components)
+	assert.Equal(t, []string{"Qux"}, a.ArticulationPoints)
+	assert.Equal(t, [][2]string{{"Ann", "Bee"}, {"Qux", "Zed"}}, a.Bridges)
+	assert.Equal(t, 3, a.Diameter)
+	assert.True(t, a.DiameterExact)
+	assert.Equal(t, []string{"One"}, a.Isolated)
+
+	// destroying Qux leaves Zed alone and Bar-Foo-Baz a line
+	qux, err := m.GetCity("Qux")
+	require.Nil(t, err)
+	require.Nil(t, destroy(qux))
+	a = Analyze(m)
+	assert.Equal(t, 7, a.Cities)
+	assert.Equal(t, 3, a.Roads)
+	assert.Equal(t, []int{2, 4, 1}, a.Degrees)
+	assert.Equal(t, []int{3, 2, 1, 1}, a.Components)
+	assert.Equal(t, []string{"Foo"}, a.ArticulationPoints)
+	assert.Equal(t, [][2]string{{"Ann", "Bee"}, {"Bar", "Foo"}, {"Baz", "Foo"}}, a.Bridges)
+	assert.Equal(t, 2, a.Diameter)
+	assert.Equal(t, []string{"One", "Zed"}, a.Isolated)
+}
+
+func TestAnalyzeLine(t *testing.T) {
+	// the cities joining a cycle to its tails split the map, and two roads
+	// between the same cities are not bridges
+	a := Analyze(textMap(t, "A east=B\nB east=C north=D\nD east=F\nF south=C\nC east=E\n"))
+	assert.Equal(t, []string{"B", "C"}, a.ArticulationPoints)
+	assert.Equal(t, [][2]string{{"A", "B"}, {"C", "E"}}, a.Bridges)
+	assert.Equal(t, 3, a.Diameter)
+
+	a = Analyze(textMap(t, "A east=B north=B\n"))
+	assert.Empty(t, a.Bridges)
+	assert.Equal(t, 2, a.Roads)
+}
+
+func TestAnalyzeOneWay(t *testing.T) {
+	// one-way roads join their cities like the others, but count once
+	a := Analyze(textMap(t, "A east->B\nB east->C north=D\nD east->C\n"))
+	assert.Equal(t, 4, a.Roads)
+	assert.Equal(t, []int{4}, a.Components)
+	assert.Equal(t, []string{"B"}, a.ArticulationPoints)
+	assert.Equal(t, [][2]string{{"A", "B"}}, a.Bridges)
+	assert.Equal(t, 2, a.Diameter)
+}
+
+func TestAnalyzeGrid(t *testing.T) {
+	m, _ := gridMap(400, 1)
+	a := Analyze(m)
+	assert.Equal(t, 400, a.Cities)
+	assert.Equal(t, 2*20*19, a.Roads)
+	assert.Equal(t, []int{400}, a.Components)
+	assert.Equal(t, []int{0, 0, 4, 72, 324}, a.Degrees)
+	assert.Empty(t, a.ArticulationPoints)
+	assert.Empty(t, a.Bridges)
+	assert.Equal(t, 38, a.Diameter)
+
+	// the two searches find the diameter of a grid from a corner
+	defer func(limit int) { exactDiameterCities = limit }(exactDiameterCities)
+	exactDiameterCities = 10
+	a = Analyze(m)
+	assert.False(t, a.DiameterExact)
+	assert.Equal(t, 38, a.Diameter)
+}
@@ -0,0 +1,112 @@
+package cosmos
+
+import (
+	"io"
+	"log/slog"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+// gridMap builds a square grid with the given number of cities, roads between
+// every pair of neighbours and an alien every 10 cities, placed at random
+func gridMap(cities int, seed int64) (*Map, int) {
+	side := 1
+	for side*side < cities {
+		side++
+	}
+	m := CreateMap()
+	grid := make([]*City, side*side)
+	for i := range grid {
+		grid[i] = NewCity(strconv.Itoa(i%side) + "-" + strconv.Itoa(i/side))
+		m.SetCity(grid[i])
+	}
+	for i, city := range grid {
+		if x := i % side; x+1 < side {
+			city.AddRoad(NewRoad(city, East, grid[i+1]))
+			grid[i+1].AddRoad(NewRoad(grid[i+1], West, city))
+		}
+		if i+side < len(grid) {
+			city.AddRoad(NewRoad(city, South, grid[i+side]))
+			grid[i+side].AddRoad(NewRoad(grid[i+side], North, city))
+		}
+	}
+	aliens := len(grid) / 10
+	rng := rand.New(rand.NewSource(seed))
+	for i := 0; i < aliens; i++ {
+		city := grid[rng.Intn(len(grid))]
+		alien := NewAlien(i, city)
+		city.AddAlien(alien)
+		m.Aliens.Set(i, alien)
+	}
+	return m, aliens
+}
+
+func BenchmarkSimulate(b *testing.B) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, cities := range []int{10000, 100000, 1000000} {
+		for _, workers := range []int{0, 4} {
+			benchmarkSimulate(b, cities, workers, logger)
+		}
+	}
+}
+
+// benchmarkSimulate runs 100 rounds on a grid with the given number of
+// workers, 0 for the sequential engine
+func benchmarkSimulate(b *testing.B, cities int, workers int, logger *slog.Logger) {
+	name := "cities=" + strconv.Itoa(cities)
+	if workers > 0 {
+		name += "/workers=" + strconv.Itoa(workers)
+	}
+	b.Run(name, func(b *testing.B) {
+		cfg := DefaultConfig()
+		cfg.Seed = 1
+		cfg.MaxRounds = 100
+		cfg.Workers = workers
+		rounds := 0
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			m, aliens := gridMap(cities, int64(i))
+			sim := NewSimulation(m, aliens)
+			sim.SetConfig(cfg)
+			sim.SetLogger(logger)
+			sim.SetMetrics(NewMetrics())
+			b.StartTimer()
+			_, round, err := sim.Run()
+			if err != nil {
+				b.Fatal(err)
+			}
+			rounds += round
+		}
+		b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(rounds), "ns/round")
+	})
+}
+
+func BenchmarkChooseRoad(b *testing.B) {
+	m, _ := gridMap(100, 1)
+	city, _ := m.GetCity("5-5")
+	sim := NewSimulation(m, 0)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sim.chooseRoad(city)
+	}
+}
+
+func TestPlayRoundAllocs(t *testing.T) {
+	// a single alien never fights, so rounds only move it
+	m := ringMap(8, 1)
+	sim := NewSimulation(m, 1)
+	sim.living = sim.livingAliens()
+	// every city has held the alien once
+	for i := 0; i < 50; i++ {
+		sim.playRound()
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		sim.playRound()
+	})
+	if allocs != 0 {
+		t.Errorf("a round allocates %v times", allocs)
+	}
+}
@@ -0,0 +1,169 @@
+package cosmos
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// ========== Checkpoints ==========
+
+// checkpointVersion is the version of the format of the checkpoints
+const checkpointVersion = 3
+
+// checkpoint is everything needed to go on with a simulation: the whole map,
+// with its destroyed cities and roads, the aliens, the rules, the round and
+// the state of the random moves
+type checkpoint struct {
+	Version    int
+	Config     Config
+	Directions []Direction // of the direction set of the map
+	Seed       int64
+	RNGState   uint64 // state of the source of the random moves
+	Round      int
+	AliensLeft int
+	Cities     []checkpointCity // in the order of their ids
+	Aliens     []checkpointAlien
+}
+
+// checkpointCity is a city and its roads in a checkpoint
+type checkpointCity struct {
+	Name      string
+	Destroyed bool
+	Roads     []int32 // index of the destination of the road in each slot, or -1
+	Available []bool
+	OneWay    []bool
+}
+
+// checkpointAlien is an alien in a checkpoint
+type checkpointAlien struct {
+	ID    int
+	City  int32 // index of the city of the alien, or -1 if it wasn't placed
+	Alive bool
+}
+
+// Checkpoints makes the simulation call save before every round that is a
+// multiple of every, except the first round of a run, so save can write a
+// checkpoint from which the run continues as if it was never stopped. An
+// error from save stops the simulation. Zero stops the checkpoints.
+func (s *Simulation) Checkpoints(every int, save func(round int) error) {
+	s.checkpointEvery = every
+	s.saveCheckpoint = save
+}
+
+// WriteCheckpoint writes the whole state of the simulation, including its
+// map, in a binary format. Checkpoints written while the simulation runs are
+// only exact from the function given to Checkpoints, between two rounds.
+func (s *Simulation) WriteCheckpoint(w io.Writer) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	cp := checkpoint{
+		Version:    checkpointVersion,
+		Config:     s.cfg,
+		Directions: s.m.graph.directions.directions,
+		Seed:       s.seed,
+		RNGState:   s.source.state,
+		Round:      s.round,
+		AliensLeft: s.aliensLeft,
+		Cities:     make([]checkpointCity, 0, s.m.CitiesLen()),
+		Aliens:     make([]checkpointAlien, 0, len(s.m.Aliens)),
+	}
+	g := s.m.graph
+	for id := 0; id < g.CitiesLen(); id++ {
+		cc := checkpointCity{
+			Name:      g.CityName(id),
+			Destroyed: g.IsDestroyed(id),
+			Roads:     make([]int32, g.slots),
+			Available: make([]bool, g.slots),
+			OneWay:    make([]bool, g.slots),
+		}
+		for slot := range cc.Roads {
+			i := g.slots*id + slot
+			cc.Roads[slot] = g.roads[i]
+			cc.Available[slot] = g.available(i)
+			cc.OneWay[slot] = g.isOneWay(i)
+		}
+		cp.Cities = append(cp.Cities, cc)
+	}
+	for _, id := range s.m.Aliens.IDs() {
+		alien := s.m.Aliens[id]
+		ca := checkpointAlien{ID: id, City: -1, Alive: alien.alive}
+		if alien.position != nil {
+			ca.City = int32(alien.position.id)
+		}
+		cp.Aliens = append(cp.Aliens, ca)
+	}
+	return gob.NewEncoder(w).Encode(cp)
+}
+
+// ReadCheckpoint reads a checkpoint written by WriteCheckpoint and returns
+// its map and a simulation that goes on from it
+func ReadCheckpoint(r io.Reader) (*Map, *Simulation, error) {
+	var cp checkpoint
+	err := gob.NewDecoder(r).Decode(&cp)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Couldn't read checkpoint: %w", err)
+	}
+	if cp.Version != checkpointVersion {
+		return nil, nil, fmt.Errorf("Checkpoint version %v is not supported", cp.Version)
+	}
+	set, err := NewDirectionSet("checkpoint", cp.Directions...)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, known := range directionSets {
+		if slices.Equal(known.directions, set.directions) {
+			set = known
+		}
+	}
+	g := NewGraphDirections(set)
+	for i, cc := range cp.Cities {
+		if g.city([]byte(cc.Name)) != i {
+			return nil, nil, fmt.Errorf("City %v is twice in the checkpoint", cc.Name)
+		}
+		if cc.Destroyed {
+			g.Destroy(i)
+		}
+	}
+	for i, cc := range cp.Cities {
+		for slot, to := range cc.Roads {
+			if to < 0 {
+				continue
+			}
+			if int(to) >= len(cp.Cities) {
+				return nil, nil, fmt.Errorf("City %v has a road to city %v, which is not in the checkpoint", cc.Name, to)
+			}
+			if slot >= len(compass) || slot >= len(cc.Available) {
+				return nil, nil, fmt.Errorf("City %v has a road in slot %v, which is not a direction", cc.Name, slot)
+			}
+			// the roads of the destroyed cities went with them
+			available := cc.Available[slot] || g.IsDestroyed(i) || g.IsDestroyed(int(to))
+			g.setRoad(i, slot, int(to), slot < len(cc.OneWay) && cc.OneWay[slot], available)
+		}
+	}
+	m := g.Map()
+	for _, ca := range cp.Aliens {
+		if int(ca.City) >= len(cp.Cities) {
+			return nil, nil, fmt.Errorf("Alien %v is in city %v, which is not in the checkpoint", ca.ID, ca.City)
+		}
+		alien := NewAlien(ca.ID, nil)
+		if ca.City >= 0 {
+			alien.position = m.city(int(ca.City))
+		}
+		alien.alive = ca.Alive
+		if alien.alive && alien.position != nil {
+			alien.position.AddAlien(alien)
+		}
+		m.Aliens.Set(alien.id, alien)
+	}
+	cp.Config.Seed = cp.Seed
+	sim := NewSimulation(m, cp.AliensLeft)
+	err = sim.SetConfig(cp.Config)
+	if err != nil {
+		return nil, nil, err
+	}
+	sim.round = cp.Round
+	sim.rng, sim.source = newRNG(cp.RNGState)
+	return m, sim, nil
+}
@@ -0,0 +1,93 @@
+package cosmos
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpointResume(t *testing.T) {
+	for _, workers := range []int{0, 2} {
+		cfg := DefaultConfig()
+		cfg.Seed = 6
+		cfg.MaxRounds = 60
+		cfg.FightThreshold = 3
+		cfg.Workers = workers
+
+		// an uninterrupted run that saves a checkpoint every 20 rounds
+		sim, events := recordedSimulation(t, cfg)
+		var checkpoints [][]byte
+		sim.Checkpoints(20, func(round int) error {
+			var buf bytes.Buffer
+			err := sim.WriteCheckpoint(&buf)
+			checkpoints = append(checkpoints, buf.Bytes())
+			return err
+		})
+		left, rounds, err := sim.Run()
+		require.Nil(t, err)
+		require.Equal(t, 60, rounds)
+		require.Len(t, checkpoints, 2)
+
+		// resuming from the checkpoint of round 40 ends the same way
+		m, resumed, err := ReadCheckpoint(bytes.NewReader(checkpoints[1]))
+		require.Nil(t, err)
+		resumed.SetMetrics(NewMetrics())
+		var resumedEvents []Event
+		resumed.Subscribe(func(e Event) {
+			resumedEvents = append(resumedEvents, e)
+		})
+		assert.Nil(t, CheckInvariants(m))
+		resumedLeft, resumedRounds, err := resumed.Run()
+		require.Nil(t, err)
+		assert.Equal(t, left, resumedLeft)
+		assert.Equal(t, rounds, resumedRounds)
+		assert.NotEmpty(t, resumedEvents)
+		assert.Equal(t, fromRound(*events, 40), resumedEvents)
+		assert.Equal(t, battleState(sim.m), battleState(m))
+		assert.Equal(t, int64(6), resumed.Seed())
+	}
+}
+
+func TestCheckpointOneWayRoads(t *testing.T) {
+	m := textMap(t, "Lake east->Mill\nMill east->Sea north=Bridge\n")
+	var buf bytes.Buffer
+	require.Nil(t, NewSimulation(m, 0).WriteCheckpoint(&buf))
+	resumed, _, err := ReadCheckpoint(&buf)
+	require.Nil(t, err)
+	assert.Nil(t, CheckInvariants(resumed))
+	assert.True(t, Diff(m, resumed).Empty())
+	mill, err := resumed.GetCity("Mill")
+	require.Nil(t, err)
+	assert.Len(t, mill.GetIncoming(), 1)
+}
+
+func TestCheckpointSaveError(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 6
+	cfg.MaxRounds = 10
+	cfg.FightThreshold = 3
+
+	// the battle of the seed is still going at round 5
+	sim, _ := recordedSimulation(t, cfg)
+	_, rounds, err := sim.Run()
+	require.Nil(t, err)
+	require.Equal(t, 10, rounds)
+
+	sim, _ = recordedSimulation(t, cfg)
+	var saved []int
+	sim.Checkpoints(5, func(round int) error {
+		saved = append(saved, round)
+		return assert.AnError
+	})
+	_, _, err = sim.Run()
+	assert.EqualError(t, err, "Couldn't save checkpoint at round 5: "+assert.AnError.Error())
+	assert.Equal(t, []int{5}, saved)
+}
+
+func TestReadCheckpointErrors(t *testing.T) {
+	_, _, err := ReadCheckpoint(strings.NewReader("not a checkpoint"))
+	assert.ErrorContains(t, err, "Couldn't read checkpoint")
+}
@@ -0,0 +1,57 @@
+package cosmos
+
+import (
+	"fmt"
+)
+
+// ========== Config ==========
+
+// Strategy decides which of the available roads an alien takes
+type Strategy string
+
+const (
+	// RandomStrategy takes any of the available roads at random
+	RandomStrategy Strategy = "random"
+	// FirstStrategy takes the first available road, in the order of the
+	// slots of Roads: north, south, east, west, then the other directions
+	FirstStrategy Strategy = "first"
+)
+
+// Config holds the rules of a simulation
+type Config struct {
+	Seed            int64    // seed of the random moves, 0 picks one from the clock
+	MaxRounds       int      // rounds after which the battle stops
+	Strategy        Strategy // how aliens choose the road they take
+	FightThreshold  int      // aliens that must meet in a city to fight
+	CheckInvariants bool     // check the invariants of the map after every step
+	Workers         int      // regions simulated in parallel, 0 runs the sequential engine
+}
+
+// DefaultConfig returns the rules of the original battle: aliens move at
+// random for up to 10,000 rounds and any two aliens that meet fight
+func DefaultConfig() Config {
+	return Config{
+		MaxRounds:      10000,
+		Strategy:       RandomStrategy,
+		FightThreshold: 2,
+	}
+}
+
+// Validate checks that the rules can run a simulation
+func (cfg Config) Validate() error {
+	if cfg.MaxRounds < 0 {
+		return fmt.Errorf("Round limit can't be negative")
+	}
+	if cfg.Workers < 0 {
+		return fmt.Errorf("Number of workers can't be negative")
+	}
+	if cfg.FightThreshold < 2 {
+		return fmt.Errorf("At least 2 aliens are needed for a fight, got %v", cfg.FightThreshold)
+	}
+	switch cfg.Strategy {
+	case RandomStrategy, FirstStrategy:
+		return nil
+	default:
+		return fmt.Errorf("%v is not a valid strategy", cfg.Strategy)
+	}
+}
@@ -1,79 +1,295 @@
 package cosmos
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"math/rand"
-	"strconv"
+	"sync"
 	"time"
 )
 
-// Simulate simulates a battle of aliens
-func Simulate(m *Map, aliensLeft int) (int, int, error) {
-	var round = 0 // number of times all the aliens have moved in the map
+// Simulation is a battle of aliens running over a map. Every move, fight
+// and destruction is reported to the subscribed listeners as it happens.
+// Other goroutines can read the state of the battle while it runs through
+// View, Progress, City and Alien.
+type Simulation struct {
+	mu         sync.RWMutex // held by the engine while it changes the map
+	m          *Map
+	aliensLeft int
+	round      int // number of times all the aliens have moved in the map
+	cfg        Config
+	seed       int64
+	rng        *rand.Rand
+	source     *splitMixSource // source of rng, whose state is saved
+	listeners  []Listener
+	living     []*Alien // living aliens in the order of their ids
+	metrics    *Metrics
+	logger     *slog.Logger
+	ctx        context.Context // context of the run, which stops it when done
+
+	snapshotEvery int         // rounds between the kept snapshots, 0 for none
+	snapshots     []*Snapshot // snapshots kept while running
+
+	checkpointEvery int                   // rounds between the checkpoints, 0 for none
+	saveCheckpoint  func(round int) error // saves a checkpoint before a round
+}
+
+// NewSimulation creates a simulation for the aliens already placed in the map,
+// following the default rules
+func NewSimulation(m *Map, aliensLeft int) *Simulation {
+	s := &Simulation{
+		m:          m,
+		aliensLeft: aliensLeft,
+		metrics:    DefaultMetrics,
+		logger:     slog.Default(),
+		ctx:        context.Background(),
+	}
+	s.SetConfig(DefaultConfig())
+	return s
+}
+
+// SetConfig sets the rules of the simulation. A zero seed picks a new one
+// from the clock.
+func (s *Simulation) SetConfig(cfg Config) error {
+	err := cfg.Validate()
+	if err != nil {
+		return err
+	}
+	s.cfg = cfg
+	s.seed = cfg.Seed
+	if s.seed == 0 {
+		s.seed = time.Now().UnixNano()
+	}
+	s.rng, s.source = newRNG(uint64(s.seed))
+	return nil
+}
+
+// Seed returns the seed of the random moves, so a run can be reproduced
+func (s *Simulation) Seed() int64 {
+	return s.seed
+}
+
+// SetLogger sets the logger for the progress of the simulation
+func (s *Simulation) SetLogger(logger *slog.Logger) {
+	s.logger = logger
+}
+
+// SetMetrics sets where the simulation records its metrics
+func (s *Simulation) SetMetrics(metrics *Metrics) {
+	s.metrics = metrics
+}
+
+// Subscribe registers a listener for the events of the simulation. Listeners
+// are called synchronously, so they must not block. They are called without
+// the lock of the simulation, so they can read its state.
+func (s *Simulation) Subscribe(l Listener) {
+	s.listeners = append(s.listeners, l)
+}
+
+// emit sends an event to every listener
+func (s *Simulation) emit(e Event) {
+	for _, l := range s.listeners {
+		l(e)
+	}
+}
 
+// Run runs the battle and returns the aliens left and the rounds simulated
+func (s *Simulation) Run() (int, int, error) {
+	return s.RunContext(context.Background())
+}
+
+// RunContext runs the battle like Run until the context is done, in which
+// case it returns the error of the context. The sequential engine stops after
+// the move in progress and the parallel one after the round in progress, so
+// the map is always left consistent.
+func (s *Simulation) RunContext(ctx context.Context) (int, int, error) {
+	s.ctx = ctx
+	start := time.Now()
+	s.metrics.start()
+	aliensLeft, round, err := s.run()
+	s.metrics.finish(round, time.Since(start), err)
+	return aliensLeft, round, err
+}
+
+func (s *Simulation) run() (int, int, error) {
+	if s.cfg.Workers > 0 {
+		return s.runParallel()
+	}
+	if s.living == nil {
+		s.living = s.livingAliens()
+	}
+	start := s.round
 	// Iterate over aliens until all of them are dead or
-	// each​ ​alien​ ​has​ ​moved​ ​at​ ​least​ ​10,000​ ​times
-	rand.Seed(time.Now().Unix())
-	for aliensLeft > 0 && round < 10000 {
-		if round%1000 == 0 {
-			fmt.Println("simulating round " + strconv.Itoa(round) + "...")
+	// each alien has moved the maximum number of rounds
+	for s.aliensLeft > 0 && s.round < s.cfg.MaxRounds {
+		if s.round%1000 == 0 {
+			s.logger.Info("Simulating round...", "round", s.round, "aliens", s.aliensLeft)
+		}
+		err := s.beforeRound(start)
+		if err != nil {
+			return -1, -1, err
+		}
+		err = s.playRound()
+		if err != nil {
+			return -1, -1, err
+		}
+		s.nextRound()
+	}
+	return s.aliensLeft, s.round, nil
+}
+
+// nextRound starts a new round
+func (s *Simulation) nextRound() {
+	s.mu.Lock()
+	s.round++
+	s.mu.Unlock()
+}
+
+// beforeRound keeps the snapshots and saves the checkpoints due before the
+// round, except the checkpoint of the round the run started at
+func (s *Simulation) beforeRound(start int) error {
+	err := s.stopped()
+	if err != nil {
+		return err
+	}
+	s.keepSnapshot()
+	if s.checkpointEvery > 0 && s.round > start && s.round%s.checkpointEvery == 0 {
+		err := s.saveCheckpoint(s.round)
+		if err != nil {
+			return fmt.Errorf("Couldn't save checkpoint at round %v: %w", s.round, err)
+		}
+	}
+	return nil
+}
+
+// stopped returns the error of the context of the run once it is done
+func (s *Simulation) stopped() error {
+	select {
+	case <-s.ctx.Done():
+		return fmt.Errorf("Simulation stopped at round %v: %w", s.round, s.ctx.Err())
+	default:
+		return nil
+	}
+}
+
+// livingAliens returns the living aliens of the map in the order of their ids
+func (s *Simulation) livingAliens() []*Alien {
+	var living = make([]*Alien, 0, s.m.Aliens.Len())
+	for _, id := range s.m.Aliens.IDs() {
+		if alien := s.m.Aliens[id]; alien.IsAlive() {
+			living = append(living, alien)
+		}
+	}
+	return living
+}
+
+// playRound moves every living alien once. Aliens move in the order of their
+// ids, so a seed always reproduces the same battle. The aliens that died in
+// the previous round are dropped as the round goes, so a round costs time
+// proportional to the living aliens and allocates nothing unless there are
+// listeners or fights.
+func (s *Simulation) playRound() error {
+	living := s.living[:0]
+	for _, alien := range s.living {
+		if !alien.alive {
+			continue
+		}
+		living = append(living, alien)
+		currentCity := alien.position
+		if currentCity == nil {
+			return fmt.Errorf("Alien %v hasn't been placed", alien.id)
+		}
+		slot := s.chooseRoad(currentCity)
+		if slot < 0 {
+			// trapped in a city without roads
+			continue
+		}
+		s.mu.Lock()
+		dest, err := move(alien, slot)
+		s.mu.Unlock()
+		if err != nil {
+			return err
 		}
-		for i, alien := range m.Aliens {
-			// check if alien is alive
-			if alien.IsAlive() {
-				currentCity := alien.GetPosition()
-				// select a valid direction to move from alien current city
-				if &currentCity == nil {
-					return -1, -1, fmt.Errorf("Alien hasn't been placed")
-				}
-				if currentCity.GetRoads().AvailableRoads() == 0 {
-					continue
-				}
-				selectedRoad, _ := currentCity.GetRoad(rand.Intn(4))
-				for selectedRoad == nil || !selectedRoad.IsAvailable() {
-					selectedRoad, _ = currentCity.GetRoad(rand.Intn(4))
-				}
-				if !selectedRoad.IsAvailable() {
-					return -1, -1, fmt.Errorf("City %v is destroyed", currentCity.Name())
-				}
-				direction := selectedRoad.GetDirection()
-				if direction == Destroyed {
-					return -1, -1, fmt.Errorf("City %v is destroyed", currentCity.Name())
-				}
-				intDir := direction.IntValue()
-				// move
-				dest, err := move(alien, intDir)
-				if err != nil {
-					return -1, -1, err
-				}
-				// check if there is more than one alien in the city to fight
-				if dest.HasFight() {
-					var aliensInCity = dest.aliens.Len()
-					fight(i, dest, round)
-					aliensLeft -= aliensInCity
-				}
+		if len(s.listeners) > 0 {
+			s.emit(Event{
+				Type:      MoveEvent,
+				Round:     s.round,
+				Aliens:    []int{alien.id},
+				City:      dest.name,
+				From:      currentCity.name,
+				Direction: compass[slot],
+			})
+		}
+		// check if there are enough aliens in the city to fight
+		if dest.aliens.Len() >= s.cfg.FightThreshold {
+			var aliensInCity = dest.aliens.IDs()
+			s.emit(Event{Type: FightEvent, Round: s.round, Aliens: aliensInCity, City: dest.name})
+			err = s.fight(alien.id, dest, len(aliensInCity))
+			if err != nil {
+				return err
 			}
+			s.emit(Event{Type: DestroyEvent, Round: s.round, Aliens: aliensInCity, City: dest.name})
 		}
-		round++
+		if s.cfg.CheckInvariants {
+			err = CheckInvariants(s.m)
+			if err != nil {
+				return fmt.Errorf("Invariants broken after alien %v moved in round %v: %w", alien.id, s.round, err)
+			}
+		}
+		err = s.stopped()
+		if err != nil {
+			// the living aliens are found again if the run goes on
+			s.living = nil
+			return err
+		}
+	}
+	// clear the tail so the dropped aliens can be collected
+	clear(s.living[len(living):])
+	s.living = living
+	return nil
+}
+
+// chooseRoad selects the slot of the road an alien in the city takes
+// according to the strategy, or -1 if the city has no available roads. The
+// random strategy draws a single number among the available roads.
+func (s *Simulation) chooseRoad(city *City) int {
+	available, n := s.m.graph.availableSlots(city.id)
+	switch {
+	case n == 0:
+		return -1
+	case n == 1 || s.cfg.Strategy == FirstStrategy:
+		return available[0]
+	default:
+		return available[s.rng.Intn(n)]
 	}
-	return aliensLeft, round, nil
 }
 
-// Move moves the alien from origin to a random destination if there's a path between them
-func move(alien *Alien, direction int) (*City, error) {
-	// get destination city from the destination value
+// Simulate simulates a battle of aliens
+func Simulate(m *Map, aliensLeft int) (int, int, error) {
+	return NewSimulation(m, aliensLeft).Run()
+}
+
+// Move moves the alien from its city down the road in the given slot, if the
+// road is available
+func move(alien *Alien, slot int) (*City, error) {
 	var currentCity = alien.GetPosition()
-	var road, err = currentCity.GetRoad(direction)
-	if err != nil {
-		return nil, err
+	if currentCity.m == nil {
+		return nil, fmt.Errorf("City %v is not in a map", currentCity.Name())
+	}
+	if slot < 0 || slot >= len(compass) {
+		return nil, fmt.Errorf("Invalid direction")
 	}
-	var destination = road.Destination()
-	if !road.IsAvailable() || destination == nil {
-		return nil, fmt.Errorf("Road to" + destination.Name() +
-			" is already destroyed")
+	to, available := currentCity.m.graph.roadAt(currentCity.id, slot)
+	if to < 0 {
+		return nil, fmt.Errorf("City %v has no road %v", currentCity.Name(), compass[slot])
+	}
+	var destination = currentCity.m.city(to)
+	if !available {
+		return nil, fmt.Errorf("Road to %v is already destroyed", destination.Name())
 	}
 	// remove the alien from origin City
-	err = currentCity.RemoveAlien(alien.ID())
+	err := currentCity.RemoveAlien(alien.ID())
 	if err != nil {
 		return nil, err
 	}
@@ -90,43 +306,42 @@ func move(alien *Alien, direction int) (*City, error) {
 	return destination, nil
 }
 
-// RemovePaths removes all the paths from the neighbour cities
-func removePaths(city *City) error {
-	for i := 0; i < 4; i++ {
-		if city.roads[i] != nil {
-			opositeDir := city.roads[i].OppositeDirection()
-			destCity := city.roads[i].Destination()
-			if destCity == nil {
-				return fmt.Errorf("Destination city does not exist")
-			}
-			destRoads := destCity.GetRoads()
-			roads, err := destRoads.Destroy(opositeDir)
-			if err != nil {
-				return err
-			}
-			destCity.roads = roads
-		}
+// destroy destroys a city by setting its bit among the destroyed cities of
+// its map, which takes along the roads from and to it
+func destroy(city *City) error {
+	if city.m == nil {
+		return fmt.Errorf("City %v is not in a map", city.Name())
 	}
+	city.m.graph.Destroy(city.id)
 	return nil
 }
 
-// Fight destroys all the roads of the city and its aliens and
-// sets the state to destroyed
-func fight(alienID int, city *City, round int) error {
+// fight resolves a fight in the city, where the given number of aliens die
+func (s *Simulation) fight(alienID int, city *City, aliens int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := fight(alienID, city)
+	if err != nil {
+		return err
+	}
+	s.metrics.fight()
+	if city.IsDestroyed() {
+		s.metrics.destroy()
+	}
+	s.aliensLeft -= aliens
+	return nil
+}
+
+// Fight destroys the city, its aliens and the roads from and to it
+func fight(alienID int, city *City) error {
 	_, Err := city.aliens.Get(alienID)
 	if Err != nil {
 		return Err
 	}
-	// Print fight between alienID and aliens in city
 	for i, alien := range city.aliens {
 		if alien.ID() == alienID {
 			continue
 		}
-		fmt.Println()
-		fmt.Println("––––––––––– Round " + strconv.Itoa(round) + " –––––––––––")
-		var msg = city.Name() + " ​has​ ​been​ ​destroyed​ ​by​ ​alien " + strconv.Itoa(alienID) +
-			"​ ​and​ ​alien​ " + strconv.Itoa(alien.ID()) + "!"
-		fmt.Println(msg)
 		aliens, err := city.aliens.Kill(i) // destroy each alien in the city
 		if err != nil {
 			return err
@@ -138,15 +353,5 @@ func fight(alienID int, city *City, round int) error {
 		return err
 	}
 	city.aliens = aliens
-	// Remove paths from neighbour cities
-	err = removePaths(city)
-	if err != nil {
-		return fmt.Errorf("Couldn't delete destination roads")
-	}
-	err = city.roads.DestroyAll() // destroy all roads
-	if err != nil {
-		return err
-	}
-	city.destroyed = true // set state of city to destroyed
-	return nil
+	return destroy(city)
 }
@@ -1,6 +1,8 @@
 package cosmos
 
 import (
+	"bytes"
+	"context"
 	"strconv"
 	"testing"
 
@@ -14,32 +16,28 @@ var totalAliens = 6
 
 func TestFight(t *testing.T) {
 	city := NewCity("Foo")
+	CreateMap().SetCity(city)
 	alien1 := NewAlien(1, city)
 	alien2 := NewAlien(2, city)
 	city.AddAlien(alien1)
 	city.AddAlien(alien2)
-	err := fight(4, city, 1)
+	err := fight(4, city)
 	assert.Error(t, err)
-	err = fight(1, city, 2)
+	err = fight(1, city)
 	assert.Nil(t, err)
 }
 
-func TestRemovePaths(t *testing.T) {
-	city := NewCity("Foo")
-	otherCity := NewCity("Bar")
-	westDir, err := StrToDir("west")
-	assert.Nil(t, err)
-	eastDir, err := StrToDir("east")
-	assert.Nil(t, err)
-	roadEast := NewRoad(city, eastDir, otherCity)
-	roadWest := NewRoad(otherCity, westDir, city)
-	err = city.AddRoad(roadEast)
-	assert.Nil(t, err)
-	err = otherCity.AddRoad(roadWest)
-	assert.Nil(t, err)
-	assert.NotNil(t, city)
-	err = removePaths(city)
-	assert.Nil(t, err)
+func TestDestroyCity(t *testing.T) {
+	m := twoCitiesMap()
+	foo, _ := m.GetCity("Foo")
+	bar, _ := m.GetCity("Bar")
+	assert.Nil(t, destroy(foo))
+	assert.True(t, foo.IsDestroyed())
+	// the roads from and to the city go with it
+	road, _ := bar.GetRoad(West.IntValue())
+	assert.False(t, road.IsAvailable())
+	assert.Equal(t, 0, foo.GetRoads().AvailableRoads())
+	assert.EqualError(t, destroy(NewCity("Qux")), "City Qux is not in a map")
 }
 
 func TestMove(t *testing.T) {
@@ -52,8 +50,13 @@ func TestMove(t *testing.T) {
 	err = city.AddRoad(roadEast)
 	assert.Nil(t, err)
 	err = otherCity.AddRoad(roadWest)
+	_, err = move(NewAlien(1, city), 2)
+	assert.EqualError(t, err, "City Foo is not in a map")
+	CreateMap().SetCity(city)
 	alien := NewAlien(1, city)
 	city.AddAlien(alien)
+	_, err = move(alien, 0)
+	assert.EqualError(t, err, "City Foo has no road north")
 	pos, err := move(alien, 2)
 	assert.Nil(t, err)
 	assert.Equal(t, otherCity, pos)
@@ -72,37 +75,36 @@ func TestSimulate(t *testing.T) {
 	assert.Equal(t, East, eastDir)
 	for i := 0; i < totalAliens; i++ {
 		city := NewCity("City" + strconv.Itoa(i))
-		m.CitiesIDName[i] = "City" + strconv.Itoa(i)
 
 		alien := NewAlien(i, city)
 		err := city.AddAlien(alien)
 		assert.Nil(t, err)
 		// adds roads to the next and previous cities
 		if i > 0 {
-			prevCity, err := m.GetCity(m.CitiesIDName[i-1])
+			prevCity, err := m.GetCity(m.CityName(i - 1))
 			assert.Nil(t, err)
 			roadEast := NewRoad(prevCity, eastDir, city)
 			err = prevCity.AddRoad(roadEast)
 			assert.Nil(t, err)
-			assert.NotNil(t, prevCity.roads[2])
+			assert.NotNil(t, prevCity.GetRoads()[2])
 			roadWest := NewRoad(city, westDir, prevCity)
 			err = city.AddRoad(roadWest)
 			assert.Nil(t, err)
-			assert.NotNil(t, city.roads[3])
+			assert.NotNil(t, city.GetRoads()[3])
 		}
 		if i == totalAliens-1 {
-			zeroCity, err := m.GetCity(m.CitiesIDName[0])
+			zeroCity, err := m.GetCity(m.CityName(0))
 			assert.Nil(t, err)
 			roadEast := NewRoad(city, eastDir, zeroCity)
 			err = city.AddRoad(roadEast)
 			assert.Nil(t, err)
 			_, err = city.GetRoad(2)
 			assert.Nil(t, err)
-			assert.NotNil(t, city.roads[3])
+			assert.NotNil(t, city.GetRoads()[3])
 			roadWest := NewRoad(zeroCity, westDir, city)
 			err = zeroCity.AddRoad(roadWest)
 			assert.Nil(t, err)
-			assert.NotNil(t, zeroCity.roads[3])
+			assert.NotNil(t, zeroCity.GetRoads()[3])
 			_, err = city.GetRoad(3)
 			assert.Nil(t, err)
 		}
@@ -113,3 +115,428 @@ func TestSimulate(t *testing.T) {
 	_, _, err = Simulate(m, totalAliens)
 	assert.Nil(t, err)
 }
+
+// twoCitiesMap creates a map with two connected cities and an alien in each
+func twoCitiesMap() *Map {
+	m := CreateMap()
+	foo := NewCity("Foo")
+	bar := NewCity("Bar")
+	foo.AddRoad(NewRoad(foo, East, bar))
+	bar.AddRoad(NewRoad(bar, West, foo))
+	m.SetCity(foo)
+	m.SetCity(bar)
+	for i, city := range []*City{foo, bar} {
+		alien := NewAlien(i, city)
+		city.AddAlien(alien)
+		m.Aliens.Set(i, alien)
+	}
+	return m
+}
+
+func TestSimulationEvents(t *testing.T) {
+	var events []Event
+	sim := NewSimulation(twoCitiesMap(), 2)
+	sim.Subscribe(func(e Event) {
+		events = append(events, e)
+	})
+	aliensLeft, round, err := sim.Run()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, aliensLeft)
+	assert.Equal(t, 1, round)
+	assert.Len(t, events, 3)
+	assert.Equal(t, MoveEvent, events[0].Type)
+	assert.Equal(t, FightEvent, events[1].Type)
+	assert.Equal(t, []int{0, 1}, events[1].Aliens)
+	assert.Equal(t, DestroyEvent, events[2].Type)
+	assert.Equal(t, events[0].City, events[2].City)
+}
+
+func TestMetrics(t *testing.T) {
+	metrics := NewMetrics()
+	for i := 0; i < 2; i++ {
+		sim := NewSimulation(twoCitiesMap(), 2)
+		sim.SetMetrics(metrics)
+		_, _, err := sim.Run()
+		assert.Nil(t, err)
+	}
+	var buf bytes.Buffer
+	_, err := metrics.WriteTo(&buf)
+	assert.Nil(t, err)
+	out := buf.String()
+	assert.Contains(t, out, "# TYPE aliens_simulations_started_total counter\naliens_simulations_started_total 2\n")
+	assert.Contains(t, out, "aliens_simulations_finished_total 2\n")
+	assert.Contains(t, out, "aliens_simulations_active 0\n")
+	assert.Contains(t, out, "aliens_fights_total 2\n")
+	assert.Contains(t, out, "aliens_cities_destroyed_total 2\n")
+	assert.Contains(t, out, "aliens_simulation_rounds_bucket{le=\"1\"} 2\n")
+	assert.Contains(t, out, "aliens_simulation_rounds_bucket{le=\"+Inf\"} 2\n")
+	assert.Contains(t, out, "aliens_simulation_rounds_sum 2\n")
+	assert.Contains(t, out, "aliens_simulation_duration_seconds_count 2\n")
+}
+
+// ringMap creates a map with the given cities connected in a ring from west
+// to east, with an alien in each of the first aliens cities
+func ringMap(cities int, aliens int) *Map {
+	m := CreateMap()
+	for i := 0; i < cities; i++ {
+		city := NewCity("City" + strconv.Itoa(i))
+		m.SetCity(city)
+	}
+	for i := 0; i < cities; i++ {
+		city, _ := m.GetCity(m.CityName(i))
+		next, _ := m.GetCity(m.CityName((i + 1) % cities))
+		city.AddRoad(NewRoad(city, East, next))
+		next.AddRoad(NewRoad(next, West, city))
+	}
+	for i := 0; i < aliens; i++ {
+		city, _ := m.GetCity(m.CityName(i))
+		alien := NewAlien(i, city)
+		city.AddAlien(alien)
+		m.Aliens.Set(i, alien)
+	}
+	return m
+}
+
+func TestSimulationSeed(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 42
+	var runs [2][]Event
+	for i := range runs {
+		sim := NewSimulation(ringMap(8, 4), 4)
+		assert.Nil(t, sim.SetConfig(cfg))
+		assert.Equal(t, int64(42), sim.Seed())
+		sim.Subscribe(func(e Event) {
+			runs[i] = append(runs[i], e)
+		})
+		_, _, err := sim.Run()
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, runs[0], runs[1])
+}
+
+func TestSimulationConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxRounds = 3
+	cfg.FightThreshold = 3
+	sim := NewSimulation(twoCitiesMap(), 2)
+	assert.Nil(t, sim.SetConfig(cfg))
+	aliensLeft, round, err := sim.Run()
+	assert.Nil(t, err)
+	// two aliens are not enough to fight
+	assert.Equal(t, 2, aliensLeft)
+	assert.Equal(t, 3, round)
+
+	cfg = DefaultConfig()
+	cfg.Strategy = FirstStrategy
+	cfg.MaxRounds = 2
+	sim = NewSimulation(ringMap(4, 1), 1)
+	assert.Nil(t, sim.SetConfig(cfg))
+	var cities []string
+	sim.Subscribe(func(e Event) {
+		cities = append(cities, e.City)
+	})
+	_, _, err = sim.Run()
+	assert.Nil(t, err)
+	// east is the first road of every city in the ring
+	assert.Equal(t, []string{"City1", "City2"}, cities)
+
+	assert.Error(t, sim.SetConfig(Config{MaxRounds: 10, Strategy: "teleport", FightThreshold: 2}))
+	assert.Error(t, sim.SetConfig(Config{MaxRounds: 10, Strategy: RandomStrategy, FightThreshold: 1}))
+	assert.Error(t, sim.SetConfig(Config{MaxRounds: -1, Strategy: RandomStrategy, FightThreshold: 2}))
+}
+
+func TestCheckInvariants(t *testing.T) {
+	m := ringMap(4, 2)
+	assert.Nil(t, CheckInvariants(m))
+
+	// road available on only one side
+	m = ringMap(4, 0)
+	city, _ := m.GetCity("City0")
+	road, _ := city.GetRoad(East.IntValue())
+	road.Destroy()
+	assert.ErrorContains(t, CheckInvariants(m), "Road west from City1 to City0 has no available reverse road")
+
+	// alien missing from the aliens of its city
+	m = ringMap(4, 2)
+	city, _ = m.GetCity("City1")
+	city.RemoveAlien(1)
+	assert.ErrorContains(t, CheckInvariants(m), "Alien 1 is not in the aliens of its city City1")
+
+	// alien left in a destroyed city with its roads
+	m = ringMap(4, 2)
+	city, _ = m.GetCity("City0")
+	destroy(city)
+	assert.ErrorContains(t, CheckInvariants(m), "Alien 0 is in destroyed city City0")
+
+	// alien in a city but not in the map
+	m = ringMap(4, 1)
+	city, _ = m.GetCity("City2")
+	city.AddAlien(NewAlien(7, city))
+	assert.ErrorContains(t, CheckInvariants(m), "Alien 7 in city City2 is not in the aliens of the map")
+
+	// a two-way road with a reverse road that goes one way
+	m = textMap(t, "Lake east=Mill\n")
+	city, _ = m.GetCity("Mill")
+	setBit(m.graph.oneWay, m.graph.slots*city.id+West.IntValue(), true)
+	assert.ErrorContains(t, CheckInvariants(m), "Road east from Lake to Mill has no available reverse road")
+}
+
+func TestFightNextToDestroyedCity(t *testing.T) {
+	m := ringMap(4, 0)
+	first, _ := m.GetCity("City0")
+	second, _ := m.GetCity("City1")
+	for i, city := range []*City{first, first, second, second} {
+		alien := NewAlien(i, city)
+		city.AddAlien(alien)
+		m.Aliens.Set(i, alien)
+	}
+	assert.Nil(t, fight(0, first))
+	assert.Nil(t, fight(2, second))
+	assert.True(t, second.IsDestroyed())
+	assert.Nil(t, CheckInvariants(m))
+}
+
+func TestFightOneWayRoads(t *testing.T) {
+	// a river flows east through Mill, and a road crosses it north to Bridge
+	m := textMap(t, "Lake east->Mill\nMill east->Sea north=Bridge\n")
+	mill, _ := m.GetCity("Mill")
+	alien := NewAlien(0, mill)
+	mill.AddAlien(alien)
+	m.Aliens.Set(0, alien)
+	assert.Nil(t, fight(0, mill))
+	// the one-way road into Mill went with it, like the road back from Bridge
+	for _, name := range []string{"Lake", "Sea", "Bridge"} {
+		city, _ := m.GetCity(name)
+		assert.Equal(t, 0, city.GetRoads().AvailableRoads(), name)
+	}
+	assert.Nil(t, CheckInvariants(m))
+}
+
+func TestSimulationOneWayRoads(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxRounds = 20
+	cfg.CheckInvariants = true
+	for seed := int64(1); seed <= 20; seed++ {
+		// the aliens follow the river down to the Sea and can't go back
+		m := textMap(t, "Lake east->Mill\nMill east->Sea\n")
+		lake, _ := m.GetCity("Lake")
+		alien := NewAlien(0, lake)
+		lake.AddAlien(alien)
+		m.Aliens.Set(0, alien)
+		cfg.Seed = seed
+		sim := NewSimulation(m, 1)
+		assert.Nil(t, sim.SetConfig(cfg))
+		_, _, err := sim.Run()
+		assert.Nil(t, err, "seed %v", seed)
+		assert.Equal(t, []string{"0 in Sea"}, battleState(m))
+	}
+}
+
+func TestSimulationRunContext(t *testing.T) {
+	// a listener that stops the battle at the first move
+	m := ringMap(8, 6)
+	sim := NewSimulation(m, 6)
+	ctx, cancel := context.WithCancel(context.Background())
+	moves := 0
+	sim.Subscribe(func(e Event) {
+		if e.Type == MoveEvent {
+			moves++
+			cancel()
+		}
+	})
+	_, _, err := sim.RunContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.EqualError(t, err, "Simulation stopped at round 0: context canceled")
+	assert.Equal(t, 1, moves)
+	assert.Nil(t, CheckInvariants(m))
+
+	// the parallel engine stops before the next round
+	cfg := DefaultConfig()
+	cfg.Workers = 2
+	sim = NewSimulation(ringMap(8, 6), 6)
+	assert.Nil(t, sim.SetConfig(cfg))
+	_, round, err := sim.RunContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, -1, round)
+	_, round = sim.Progress()
+	assert.Equal(t, 0, round)
+}
+
+func TestSimulationCheckInvariants(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxRounds = 200
+	cfg.CheckInvariants = true
+	for seed := int64(1); seed <= 50; seed++ {
+		cfg.Seed = seed
+		m := ringMap(8, 6)
+		sim := NewSimulation(m, 6)
+		assert.Nil(t, sim.SetConfig(cfg))
+		_, _, err := sim.Run()
+		assert.Nil(t, err, "seed %v", seed)
+		assert.Nil(t, CheckInvariants(m))
+	}
+}
+
+// battleState describes the outcome of a battle: the destroyed cities and
+// where each living alien stands
+func battleState(m *Map) []string {
+	var state []string
+	for _, name := range m.cityNames() {
+		if m.destroyed(name) {
+			state = append(state, name+" destroyed")
+		}
+	}
+	for _, id := range m.Aliens.IDs() {
+		if alien := m.Aliens[id]; alien.IsAlive() {
+			state = append(state, strconv.Itoa(id)+" in "+alien.GetPosition().Name())
+		}
+	}
+	return state
+}
+
+func TestParallelSimulationWorkers(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 7
+	cfg.MaxRounds = 200
+	cfg.CheckInvariants = true
+	var events [][]Event
+	var states [][]string
+	for _, workers := range []int{1, 2, 3, 8} {
+		m, aliens := gridMap(2500, 1)
+		cfg.Workers = workers
+		sim := NewSimulation(m, aliens)
+		sim.SetMetrics(NewMetrics())
+		assert.Nil(t, sim.SetConfig(cfg))
+		var run []Event
+		sim.Subscribe(func(e Event) {
+			run = append(run, e)
+		})
+		_, _, err := sim.Run()
+		assert.Nil(t, err)
+		events = append(events, run)
+		states = append(states, battleState(m))
+	}
+	assert.NotEmpty(t, events[0])
+	for i := 1; i < len(events); i++ {
+		assert.Equal(t, events[0], events[i])
+		assert.Equal(t, states[0], states[i])
+	}
+}
+
+func TestParallelSimulationFight(t *testing.T) {
+	// Foo east=Bar east=Baz, with an alien at each end: both reach Bar in
+	// the same round
+	m := CreateMap()
+	cities := []*City{NewCity("Foo"), NewCity("Bar"), NewCity("Baz")}
+	for _, city := range cities {
+		m.SetCity(city)
+	}
+	for i := 0; i < 2; i++ {
+		cities[i].AddRoad(NewRoad(cities[i], East, cities[i+1]))
+		cities[i+1].AddRoad(NewRoad(cities[i+1], West, cities[i]))
+	}
+	for i, city := range []*City{cities[0], cities[2]} {
+		alien := NewAlien(i, city)
+		city.AddAlien(alien)
+		m.Aliens.Set(i, alien)
+	}
+
+	sim := NewSimulation(m, 2)
+	sim.SetMetrics(NewMetrics())
+	cfg := DefaultConfig()
+	cfg.Workers = 2
+	assert.Nil(t, sim.SetConfig(cfg))
+	var events []Event
+	sim.Subscribe(func(e Event) {
+		events = append(events, e)
+	})
+	left, round, err := sim.Run()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, left)
+	assert.Equal(t, 1, round)
+	assert.Equal(t, []Event{
+		{Type: MoveEvent, Round: 0, Aliens: []int{0}, City: "Bar", From: "Foo", Direction: East},
+		{Type: MoveEvent, Round: 0, Aliens: []int{1}, City: "Bar", From: "Baz", Direction: West},
+		{Type: FightEvent, Round: 0, Aliens: []int{0, 1}, City: "Bar"},
+		{Type: DestroyEvent, Round: 0, Aliens: []int{0, 1}, City: "Bar"},
+	}, events)
+	assert.Nil(t, CheckInvariants(m))
+}
+
+func TestSimulationConcurrentReads(t *testing.T) {
+	for _, workers := range []int{0, 2} {
+		sim := NewSimulation(ringMap(64, 16), 16)
+		sim.SetMetrics(NewMetrics())
+		cfg := DefaultConfig()
+		cfg.Seed = 7
+		cfg.MaxRounds = 200
+		cfg.Workers = workers
+		assert.Nil(t, sim.SetConfig(cfg))
+		// the first event waits for a read, so the reads overlap the run.
+		// Listeners run in the goroutine of the simulation, without its lock.
+		read := make(chan struct{})
+		first := true
+		sim.Subscribe(func(e Event) {
+			if first {
+				<-read
+				first = false
+			}
+			sim.View(func(m *Map) {
+				city, _ := m.GetCity(e.City)
+				assert.NotNil(t, city)
+			})
+		})
+
+		done := make(chan struct{})
+		reads := make(chan int)
+		go func() {
+			n := 0
+			for {
+				select {
+				case <-done:
+					reads <- n
+					return
+				default:
+				}
+				left, round := sim.Progress()
+				assert.LessOrEqual(t, left, 16)
+				assert.LessOrEqual(t, round, 200)
+				city, err := sim.City("City" + strconv.Itoa(n%64))
+				assert.Nil(t, err)
+				if city.Destroyed {
+					assert.Empty(t, city.Roads)
+					assert.Empty(t, city.Aliens)
+				}
+				alien, err := sim.Alien(n % 16)
+				assert.Nil(t, err)
+				assert.NotEmpty(t, alien.City)
+				sim.View(func(m *Map) {
+					assert.Nil(t, CheckInvariants(m))
+				})
+				if n == 0 {
+					close(read)
+				}
+				n++
+			}
+		}()
+		_, _, err := sim.Run()
+		close(done)
+		assert.Nil(t, err)
+		assert.Positive(t, <-reads)
+	}
+}
+
+func TestSimulationReads(t *testing.T) {
+	sim := NewSimulation(twoCitiesMap(), 2)
+	city, err := sim.City("Foo")
+	assert.Nil(t, err)
+	assert.False(t, city.Destroyed)
+	assert.NotEmpty(t, city.Roads)
+	_, err = sim.City("Qux")
+	assert.EqualError(t, err, "Couldn't find city Qux")
+	_, err = sim.Alien(42)
+	assert.EqualError(t, err, "Couldn't find alien with id 42")
+	left, round := sim.Progress()
+	assert.Equal(t, 2, left)
+	assert.Equal(t, 0, round)
+}
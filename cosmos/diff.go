@@ -0,0 +1,174 @@
+package cosmos
+
+// ========== Diff ==========
+
+// MapDiff lists the differences between two maps. The roads from and to the
+// cities that were removed or destroyed are left out, as they went with their
+// cities, and so is the reverse of a two-way road that changed the same way.
+type MapDiff struct {
+	CitiesAdded     []string     `json:"cities_added"`
+	CitiesRemoved   []string     `json:"cities_removed"`
+	CitiesDestroyed []string     `json:"cities_destroyed"`
+	RoadsAdded      []RoadChange `json:"roads_added"`
+	RoadsRemoved    []RoadChange `json:"roads_removed"`
+	RoadsChanged    []RoadChange `json:"roads_changed"` // roads between the same cities in another direction
+}
+
+// RoadChange is a road that differs between two maps
+type RoadChange struct {
+	From         string    `json:"from"`
+	To           string    `json:"to"`
+	Direction    Direction `json:"direction"`               // in the first map, or in the second if added
+	NewDirection Direction `json:"new_direction,omitempty"` // in the second map, if changed
+	OneWay       bool      `json:"one_way,omitempty"`
+}
+
+// Diff compares two maps. The cities of a map are the ones that were not
+// destroyed, and their roads the available ones, so a city destroyed in the
+// second map is reported as destroyed and one missing from it as removed.
+// Maps read from files have no destroyed cities, since the .txt format leaves
+// them out, so their destroyed cities show up as removed.
+func Diff(a, b *Map) MapDiff {
+	d := MapDiff{
+		CitiesAdded:     []string{},
+		CitiesRemoved:   []string{},
+		CitiesDestroyed: []string{},
+		RoadsAdded:      []RoadChange{},
+		RoadsRemoved:    []RoadChange{},
+		RoadsChanged:    []RoadChange{},
+	}
+	for _, name := range a.cityNames() {
+		if a.destroyed(name) {
+			continue
+		}
+		switch id := b.graph.lookupString(name); {
+		case id < 0:
+			d.CitiesRemoved = append(d.CitiesRemoved, name)
+		case b.graph.IsDestroyed(id):
+			d.CitiesDestroyed = append(d.CitiesDestroyed, name)
+		}
+	}
+	for _, name := range b.cityNames() {
+		if !b.destroyed(name) && (a.graph.lookupString(name) < 0 || a.destroyed(name)) {
+			d.CitiesAdded = append(d.CitiesAdded, name)
+		}
+	}
+	// the roads of the cities in both maps, and of the added cities
+	reported := make(map[reportedChange]bool)
+	for _, name := range b.cityNames() {
+		if b.destroyed(name) {
+			continue
+		}
+		var before, after []RoadChange
+		if id := a.graph.lookupString(name); id >= 0 && !a.graph.IsDestroyed(id) {
+			before = liveRoads(a, id, b)
+		}
+		after = liveRoads(b, b.graph.lookupString(name), b)
+		d.diffRoads(before, after, reported)
+	}
+	return d
+}
+
+// Empty checks that the maps of the diff have the same cities and roads
+func (d MapDiff) Empty() bool {
+	return len(d.CitiesAdded)+len(d.CitiesRemoved)+len(d.CitiesDestroyed)+
+		len(d.RoadsAdded)+len(d.RoadsRemoved)+len(d.RoadsChanged) == 0
+}
+
+// ----- Unexported functions -----
+
+// liveRoads returns the available roads of a city of a map to cities that
+// are alive in the other map, so the roads that went with their cities are
+// left out
+func liveRoads(m *Map, id int, other *Map) []RoadChange {
+	var roads []RoadChange
+	g := m.graph
+	for slot := 0; slot < g.slots; slot++ {
+		to, ok := g.roadAt(id, slot)
+		if !ok {
+			continue
+		}
+		dest := g.CityName(to)
+		if other.graph.lookupString(dest) < 0 || other.destroyed(dest) {
+			continue
+		}
+		roads = append(roads, RoadChange{
+			From:      g.CityName(id),
+			To:        dest,
+			Direction: compass[slot],
+			OneWay:    g.isOneWay(g.slots*id + slot),
+		})
+	}
+	return roads
+}
+
+// diffRoads compares the roads of a city in both maps. Roads to the same city
+// in the same direction are the same road, and roads to the same city in
+// other directions changed direction, if both are one-way or both two-way.
+func (d *MapDiff) diffRoads(before []RoadChange, after []RoadChange, reported map[reportedChange]bool) {
+	matched := make([]bool, len(after))
+	var left []RoadChange
+	for _, road := range before {
+		found := false
+		for i, other := range after {
+			if !matched[i] && other == road {
+				matched[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			left = append(left, road)
+		}
+	}
+	for _, change := range left {
+		for i, other := range after {
+			if !matched[i] && other.To == change.To && other.OneWay == change.OneWay {
+				matched[i] = true
+				change.NewDirection = other.Direction
+				break
+			}
+		}
+		if change.NewDirection == "" {
+			d.RoadsRemoved = appendChange(d.RoadsRemoved, "removed", change, reported)
+		} else {
+			d.RoadsChanged = appendChange(d.RoadsChanged, "changed", change, reported)
+		}
+	}
+	for i, change := range after {
+		if !matched[i] {
+			d.RoadsAdded = appendChange(d.RoadsAdded, "added", change, reported)
+		}
+	}
+}
+
+// appendChange adds a road change of a kind, unless it is the reverse of a
+// two-way road that changed the same way
+func appendChange(changes []RoadChange, kind string, change RoadChange, reported map[reportedChange]bool) []RoadChange {
+	if change.OneWay {
+		return append(changes, change)
+	}
+	reverse := RoadChange{
+		From:         change.To,
+		To:           change.From,
+		Direction:    change.Direction.Opposite(),
+		NewDirection: change.NewDirection.Opposite(),
+	}
+	if reported[reportedChange{kind, reverse}] {
+		return changes
+	}
+	reported[reportedChange{kind, change}] = true
+	return append(changes, change)
+}
+
+// reportedChange is a road change of a kind already in a diff
+type reportedChange struct {
+	kind   string
+	change RoadChange
+}
+
+// destroyed checks if the city with the given name was destroyed, which a
+// city missing from the map was not
+func (m *Map) destroyed(name string) bool {
+	id := m.graph.lookupString(name)
+	return id >= 0 && m.graph.IsDestroyed(id)
+}
@@ -0,0 +1,76 @@
+package cosmos
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// textMap builds a map from its text in the .txt format
+func textMap(t *testing.T, text string) *Map {
+	g, err := LoadGraph(strings.NewReader(text))
+	require.Nil(t, err)
+	return g.Map()
+}
+
+func TestDiff(t *testing.T) {
+	a := textMap(t, "Foo north=Bar west=Baz south=Qu-ux\nBar west=Bee\n")
+	b := textMap(t, "Foo north=Bar east=Baz\nBar west=Bee\nBee north=Zed\nZed east=Qux\n")
+	d := Diff(a, b)
+	assert.Equal(t, []string{"Qux", "Zed"}, d.CitiesAdded)
+	assert.Equal(t, []string{"Qu-ux"}, d.CitiesRemoved)
+	assert.Empty(t, d.CitiesDestroyed)
+	// the roads of the added cities, but not the one to the removed Qu-ux
+	assert.Equal(t, []RoadChange{
+		{From: "Bee", To: "Zed", Direction: North},
+		{From: "Qux", To: "Zed", Direction: West},
+	}, d.RoadsAdded)
+	assert.Empty(t, d.RoadsRemoved)
+	// only one side of the road that changed
+	assert.Equal(t, []RoadChange{{From: "Baz", To: "Foo", Direction: East, NewDirection: West}}, d.RoadsChanged)
+	assert.False(t, d.Empty())
+	assert.True(t, Diff(a, textMap(t, "Bar south=Foo\nFoo west=Baz south=Qu-ux\nBee east=Bar\n")).Empty())
+}
+
+func TestDiffRoads(t *testing.T) {
+	a := textMap(t, "Foo north=Bar east=Baz\n")
+	b := textMap(t, "Foo north=Bar\nBar east=Baz\n")
+	d := Diff(a, b)
+	assert.Empty(t, d.CitiesAdded)
+	assert.Equal(t, []RoadChange{{From: "Bar", To: "Baz", Direction: East}}, d.RoadsAdded)
+	assert.Equal(t, []RoadChange{{From: "Baz", To: "Foo", Direction: West}}, d.RoadsRemoved)
+}
+
+func TestDiffOneWay(t *testing.T) {
+	// a road that becomes one-way is removed and added again, and both sides
+	// of a one-way road are reported
+	a := textMap(t, "Foo east->Bar\nBar east=Baz\nBaz east->Qux\nQux west->Baz\n")
+	b := textMap(t, "Foo east=Bar\nBar east->Baz\nBaz east->Qux\nQux north->Baz\n")
+	d := Diff(a, b)
+	assert.Equal(t, []RoadChange{
+		{From: "Bar", To: "Baz", Direction: East},
+		{From: "Foo", To: "Bar", Direction: East, OneWay: true},
+	}, d.RoadsRemoved)
+	assert.Equal(t, []RoadChange{
+		{From: "Bar", To: "Baz", Direction: East, OneWay: true},
+		{From: "Bar", To: "Foo", Direction: West},
+	}, d.RoadsAdded)
+	assert.Equal(t, []RoadChange{{From: "Qux", To: "Baz", Direction: West, NewDirection: North, OneWay: true}}, d.RoadsChanged)
+}
+
+func TestDiffBattle(t *testing.T) {
+	before := twoCitiesMap()
+	after := twoCitiesMap()
+	foo, _ := after.GetCity("Foo")
+	require.Nil(t, fight(0, foo))
+	d := Diff(before, after)
+	assert.Equal(t, []string{"Foo"}, d.CitiesDestroyed)
+	assert.Empty(t, d.CitiesRemoved)
+	// the road to Foo went with the city
+	assert.Empty(t, d.RoadsRemoved)
+	assert.Empty(t, d.RoadsChanged)
+	// and a destroyed city that is in the other map again was added
+	assert.Equal(t, []string{"Foo"}, Diff(after, before).CitiesAdded)
+}
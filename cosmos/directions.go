@@ -0,0 +1,133 @@
+package cosmos
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ========== Direction sets ==========
+
+// DirectionSet is the directions the roads of a map can take. A set holds the
+// opposite of each of its directions, so two-way roads can go back.
+type DirectionSet struct {
+	name       string
+	directions []Direction // in the order of their slots
+	slots      int         // slots of the roads of a city, up to the last direction
+}
+
+var (
+	// FourWay is the north, south, east and west directions of a grid
+	FourWay = mustDirectionSet("4", North, South, East, West)
+	// EightWay adds the diagonal directions to FourWay
+	EightWay = mustDirectionSet("8", North, South, East, West, Northeast, Southwest, Northwest, Southeast)
+	// Hex is the six directions of a grid of hexagons with flat tops
+	Hex = mustDirectionSet("hex", North, South, Northeast, Southwest, Northwest, Southeast)
+	// ThreeD adds up and down to FourWay, for maps in layers
+	ThreeD = mustDirectionSet("3d", North, South, East, West, Up, Down)
+)
+
+// directionSets are the sets that can be chosen by name
+var directionSets = []*DirectionSet{FourWay, EightWay, Hex, ThreeD}
+
+// NewDirectionSet creates a set of directions, which must hold the opposite
+// of each of them
+func NewDirectionSet(name string, directions ...Direction) (*DirectionSet, error) {
+	set := &DirectionSet{name: name}
+	for _, dir := range directions {
+		if dir.IntValue() < 0 {
+			return nil, fmt.Errorf("%v is not a valid direction", dir)
+		}
+		if set.Contains(dir) {
+			continue
+		}
+		set.directions = append(set.directions, dir)
+		set.slots = max(set.slots, dir.IntValue()+1)
+	}
+	for _, dir := range set.directions {
+		if !set.Contains(dir.Opposite()) {
+			return nil, fmt.Errorf("Direction set %v has %v but not %v", name, dir, dir.Opposite())
+		}
+	}
+	// keep the directions in the order of their slots, like the roads
+	ordered := set.directions[:0:0]
+	for _, dir := range compass {
+		if set.Contains(dir) {
+			ordered = append(ordered, dir)
+		}
+	}
+	set.directions = ordered
+	return set, nil
+}
+
+// DirectionSetByName returns one of the sets FourWay ("4"), EightWay ("8"),
+// Hex ("hex") and ThreeD ("3d"). An empty name is FourWay.
+func DirectionSetByName(name string) (*DirectionSet, error) {
+	if name == "" {
+		return FourWay, nil
+	}
+	for _, set := range directionSets {
+		if strings.EqualFold(name, set.name) {
+			return set, nil
+		}
+	}
+	return nil, fmt.Errorf("%v is not a valid direction set, use 4, 8, hex or 3d", name)
+}
+
+// Name returns the name of the set
+func (set *DirectionSet) Name() string {
+	return set.name
+}
+
+// Directions returns the directions of the set, in the order of their slots
+// in Roads
+func (set *DirectionSet) Directions() []Direction {
+	return append([]Direction(nil), set.directions...)
+}
+
+// Contains checks that a direction is in the set
+func (set *DirectionSet) Contains(dir Direction) bool {
+	for _, other := range set.directions {
+		if other == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse converts a string to one of the directions of the set, like StrToDir
+func (set *DirectionSet) Parse(str string) (Direction, error) {
+	dir, err := StrToDir(str)
+	if err != nil {
+		return "", err
+	}
+	if !set.Contains(dir) {
+		return "", fmt.Errorf("Direction %v is not in direction set %v", dir, set.name)
+	}
+	return dir, nil
+}
+
+// ----- Unexported functions -----
+
+// mustDirectionSet creates one of the predefined sets
+func mustDirectionSet(name string, directions ...Direction) *DirectionSet {
+	set, err := NewDirectionSet(name, directions...)
+	if err != nil {
+		panic(err)
+	}
+	return set
+}
+
+// parseSlot returns the road slot of a direction of the set, ignoring case
+// like StrToDir
+func (set *DirectionSet) parseSlot(word []byte) (int, error) {
+	for _, dir := range set.directions {
+		if equalLower(word, string(dir)) {
+			return dir.IntValue(), nil
+		}
+	}
+	dir, err := set.Parse(string(word))
+	if err != nil {
+		return -1, err
+	}
+	return dir.IntValue(), nil
+}
@@ -0,0 +1,110 @@
+package cosmos
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirections(t *testing.T) {
+	for slot, dir := range compass {
+		assert.Equal(t, slot, dir.IntValue())
+		assert.NotEqual(t, dir, dir.Opposite())
+		assert.Equal(t, dir, dir.Opposite().Opposite())
+	}
+	assert.Equal(t, Southwest, Northeast.Opposite())
+	assert.Equal(t, Down, Up.Opposite())
+	assert.Equal(t, Destroyed, Direction("upward").Opposite())
+	dir, err := StrToDir(" NorthEast")
+	require.Nil(t, err)
+	assert.Equal(t, Northeast, dir)
+}
+
+func TestDirectionSets(t *testing.T) {
+	for name, set := range map[string]*DirectionSet{"": FourWay, "4": FourWay, "8": EightWay, "HEX": Hex, "3d": ThreeD} {
+		found, err := DirectionSetByName(name)
+		require.Nil(t, err)
+		assert.Equal(t, set, found)
+	}
+	_, err := DirectionSetByName("5")
+	assert.EqualError(t, err, "5 is not a valid direction set, use 4, 8, hex or 3d")
+	assert.Equal(t, []Direction{North, South, Northeast, Southwest, Northwest, Southeast}, Hex.Directions())
+
+	// sets keep the order of the slots and need the opposite of every direction
+	set, err := NewDirectionSet("diagonals", Southeast, Northeast, Southwest, Northwest)
+	require.Nil(t, err)
+	assert.Equal(t, []Direction{Northeast, Southwest, Northwest, Southeast}, set.Directions())
+	_, err = NewDirectionSet("half", North, South, Up)
+	assert.EqualError(t, err, "Direction set half has up but not down")
+	_, err = NewDirectionSet("wrong", "upward")
+	assert.Error(t, err)
+
+	dir, err := ThreeD.Parse("UP")
+	require.Nil(t, err)
+	assert.Equal(t, Up, dir)
+	_, err = ThreeD.Parse("northeast")
+	assert.EqualError(t, err, "Direction northeast is not in direction set 3d")
+	_, err = ThreeD.Parse("upward")
+	assert.EqualError(t, err, "String upward is not a valid direction")
+}
+
+func TestDirectionsBattle(t *testing.T) {
+	// two layers of a 2x2 grid with diagonals, linked by up and down
+	text := "A east=B south=C southeast=D up=E\nB south=D southwest=C up=F\nC east=D up=G\nD up=H\n" +
+		"E east=F south=G southeast=H\nF south=H southwest=G\nG east=H\n"
+	_, err := LoadGraph(strings.NewReader(text))
+	assert.EqualError(t, err, "Line 1: Direction southeast is not in direction set 4")
+	set, err := NewDirectionSet("layers", append(EightWay.Directions(), Up, Down)...)
+	require.Nil(t, err)
+	g, err := LoadGraphDirections(strings.NewReader(text), set)
+	require.Nil(t, err)
+	m := g.Map()
+	assert.Equal(t, set, m.Directions())
+	a, err := m.GetCity("A")
+	require.Nil(t, err)
+	assert.Equal(t, 4, a.GetRoads().AvailableRoads())
+	h, err := m.GetCity("H")
+	require.Nil(t, err)
+	road, err := h.GetRoad(Northwest.IntValue())
+	require.Nil(t, err)
+	assert.Equal(t, "E", road.Destination().Name())
+	road, err = h.GetRoad(Down.IntValue())
+	require.Nil(t, err)
+	assert.Equal(t, "D", road.Destination().Name())
+	assert.Nil(t, CheckInvariants(m))
+
+	var out bytes.Buffer
+	_, err = g.WriteTo(&out)
+	require.Nil(t, err)
+	assert.Equal(t, "A south=C east=B southeast=D up=E\n", strings.SplitAfter(out.String(), "\n")[0])
+
+	// a battle on the map can be checkpointed and goes on from the checkpoint
+	// with the same directions
+	for id, name := range []string{"A", "H", "C", "F"} {
+		city, err := m.GetCity(name)
+		require.Nil(t, err)
+		alien := NewAlien(id, city)
+		require.Nil(t, city.AddAlien(alien))
+		m.Aliens.Set(id, alien)
+	}
+	cfg := DefaultConfig()
+	cfg.Seed = 7
+	cfg.CheckInvariants = true
+	sim := NewSimulation(m, 4)
+	require.Nil(t, sim.SetConfig(cfg))
+	var buf bytes.Buffer
+	require.Nil(t, sim.WriteCheckpoint(&buf))
+	resumedMap, resumed, err := ReadCheckpoint(&buf)
+	require.Nil(t, err)
+	assert.Equal(t, set.Directions(), resumedMap.Directions().Directions())
+	left, rounds, err := sim.Run()
+	require.Nil(t, err)
+	resumedLeft, resumedRounds, err := resumed.Run()
+	require.Nil(t, err)
+	assert.Equal(t, left, resumedLeft)
+	assert.Equal(t, rounds, resumedRounds)
+	assert.Equal(t, battleState(m), battleState(resumedMap))
+}
@@ -0,0 +1,28 @@
+package cosmos
+
+// ========== Events ==========
+
+// EventType identifies what happened during a step of the simulation
+type EventType string
+
+const (
+	// MoveEvent is emitted when an alien travels through a road
+	MoveEvent EventType = "move"
+	// FightEvent is emitted when two or more aliens meet in a city
+	FightEvent EventType = "fight"
+	// DestroyEvent is emitted when a city is destroyed after a fight
+	DestroyEvent EventType = "destroy"
+)
+
+// Event describes a single change in the state of the simulation
+type Event struct {
+	Type      EventType `json:"type"`
+	Round     int       `json:"round"`
+	Aliens    []int     `json:"aliens"`              // aliens involved in the event
+	City      string    `json:"city"`                // city where the event happened
+	From      string    `json:"from,omitempty"`      // origin city of a move
+	Direction Direction `json:"direction,omitempty"` // direction of a move
+}
+
+// Listener receives the events of a simulation as they happen
+type Listener func(Event)
@@ -0,0 +1,467 @@
+package cosmos
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"hash/maphash"
+	"io"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ========== Graph ==========
+
+// Graph is the compact storage of the cities and roads of a map, meant for
+// maps with millions of cities. Cities are identified by their index, in the
+// order they first appear, like in Map.CityName. All the names share a single
+// buffer, the roads are a flat array with the same slots per city as Roads,
+// up to the last direction of the set of the graph, and the destroyed cities
+// are a bitset. A road is available while neither of its cities is destroyed
+// and it wasn't destroyed on its own, so the roads from and to a city go with
+// it without touching them.
+type Graph struct {
+	names      []byte        // names of the cities, one after the other
+	offsets    []int         // the name of city i is names[offsets[i]:offsets[i+1]]
+	index      []int32       // open addressing table of city ids + 1, by name
+	seed       maphash.Seed  // seed of the hashes of the names
+	roads      []int32       // destination of the road of city i in slot d at slots*i+d, or -1
+	oneWay     []uint64      // bit slots*i+d is set if the road of city i in slot d is one-way
+	closed     []uint64      // bit slots*i+d is set if the road of city i in slot d was destroyed
+	destroyed  []uint64      // bit i is set if city i is destroyed
+	directions *DirectionSet // directions the roads can take
+	slots      int           // road slots of each city
+}
+
+// NewGraph creates an empty graph with the FourWay directions
+func NewGraph() *Graph {
+	return NewGraphDirections(FourWay)
+}
+
+// NewGraphDirections creates an empty graph whose roads can take the
+// directions of the set
+func NewGraphDirections(set *DirectionSet) *Graph {
+	return &Graph{
+		offsets:    []int{0},
+		index:      make([]int32, 16),
+		seed:       maphash.MakeSeed(),
+		directions: set,
+		slots:      set.slots,
+	}
+}
+
+// CitiesLen returns the total amount of cities in the graph
+func (g *Graph) CitiesLen() int {
+	return len(g.offsets) - 1
+}
+
+// Directions returns the directions the roads of the graph can take
+func (g *Graph) Directions() *DirectionSet {
+	return g.directions
+}
+
+// CityID returns the id of the city with the given name
+func (g *Graph) CityID(name string) (int, error) {
+	id := g.lookupString(name)
+	if id < 0 {
+		return -1, fmt.Errorf("Couldn't find city %v", name)
+	}
+	return int(id), nil
+}
+
+// CityName returns the name of a city
+func (g *Graph) CityName(id int) string {
+	return string(g.name(id))
+}
+
+// Road returns the destination of the road of a city in the given direction
+// and whether the road exists and is available
+func (g *Graph) Road(id int, dir Direction) (int, bool) {
+	return g.roadAt(id, dir.IntValue())
+}
+
+// OneWay checks if the road of a city in the given direction is one-way
+func (g *Graph) OneWay(id int, dir Direction) bool {
+	slot := dir.IntValue()
+	return slot >= 0 && slot < g.slots && g.isOneWay(g.slots*id+slot)
+}
+
+// AvailableRoads returns the number of available roads of a city
+func (g *Graph) AvailableRoads(id int) int {
+	available := 0
+	for _, dir := range g.directions.directions {
+		if _, ok := g.Road(id, dir); ok {
+			available++
+		}
+	}
+	return available
+}
+
+// IsDestroyed returns the current status of the city
+func (g *Graph) IsDestroyed(id int) bool {
+	return g.destroyed[id/64]&(1<<(id%64)) != 0
+}
+
+// Destroy destroys a city, along with the roads from and to it
+func (g *Graph) Destroy(id int) {
+	g.destroyed[id/64] |= 1 << (id % 64)
+}
+
+// Map returns a map whose cities and roads are the ones of the graph. The map
+// keeps them in the graph, so a change to either shows in both.
+func (g *Graph) Map() *Map {
+	m := &Map{graph: g, Aliens: InitAliens()}
+	m.grow()
+	return m
+}
+
+// WriteTo writes the cities that were not destroyed and their available roads
+// in the .txt format
+func (g *Graph) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	var line []byte
+	for id := 0; id < g.CitiesLen(); id++ {
+		if g.IsDestroyed(id) {
+			continue
+		}
+		line = append(line[:0], g.name(id)...)
+		for slot := 0; slot < g.slots; slot++ {
+			if to, ok := g.roadAt(id, slot); ok {
+				line = append(line, ' ')
+				line = append(line, compass[slot]...)
+				if g.isOneWay(g.slots*id + slot) {
+					line = append(line, "->"...)
+				} else {
+					line = append(line, '=')
+				}
+				line = append(line, g.name(to)...)
+			}
+		}
+		line = append(line, '\n')
+		n, err := w.Write(line)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// LoadGraph reads a map in the .txt format straight into a graph. It follows
+// the same rules as the parser of Map: every road goes both ways unless it is
+// one-way (north->Bar), no city has two roads in the same direction or a road
+// to itself, and empty lines are skipped. Names are copied from the input
+// into the graph without building a string for each of them. Roads take the
+// FourWay directions.
+func LoadGraph(r io.Reader) (*Graph, error) {
+	return LoadGraphDirections(r, FourWay)
+}
+
+// LoadGraphDirections reads a map in the .txt format like LoadGraph, with
+// roads in the directions of the set
+func LoadGraphDirections(r io.Reader, set *DirectionSet) (*Graph, error) {
+	g := NewGraphDirections(set)
+	err := ReadLines(r, g.parseLine)
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// ReadLines calls fn with each line of the reader, of any length, until the
+// end of the input or the first error, which is returned with the number of
+// its line. The line is only valid until fn returns.
+func ReadLines(r io.Reader, fn func(line []byte) error) error {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	var long []byte // lines that don't fit in the buffer of the reader
+	for n := 1; ; n++ {
+		chunk, err := reader.ReadSlice('\n')
+		for err == bufio.ErrBufferFull {
+			long = append(long, chunk...)
+			chunk, err = reader.ReadSlice('\n')
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		line := chunk
+		if len(long) > 0 {
+			long = append(long, chunk...)
+			line = long
+		}
+		if len(line) > 0 {
+			lineErr := fn(line)
+			if lineErr != nil {
+				return fmt.Errorf("Line %v: %w", n, lineErr)
+			}
+		}
+		long = long[:0]
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// ----- Unexported functions -----
+
+// name returns the name of a city as a slice of the shared buffer
+func (g *Graph) name(id int) []byte {
+	return g.names[g.offsets[id]:g.offsets[id+1]]
+}
+
+// lookup returns the slot of the name in the index and the id of its city,
+// or -1 with the empty slot where it would go
+func (g *Graph) lookup(name []byte) (int, int32) {
+	mask := len(g.index) - 1
+	for slot := int(maphash.Bytes(g.seed, name)) & mask; ; slot = (slot + 1) & mask {
+		id := g.index[slot] - 1
+		if id < 0 || bytes.Equal(g.name(int(id)), name) {
+			return slot, id
+		}
+	}
+}
+
+// lookupString returns the id of the city with the given name like lookup,
+// without copying the name, or -1 if there is none
+func (g *Graph) lookupString(name string) int {
+	mask := len(g.index) - 1
+	for slot := int(maphash.String(g.seed, name)) & mask; ; slot = (slot + 1) & mask {
+		id := int(g.index[slot]) - 1
+		if id < 0 || string(g.name(id)) == name {
+			return id
+		}
+	}
+}
+
+// city returns the id of the city with the given name, adding it to the
+// graph if it does not exist
+func (g *Graph) city(name []byte) int {
+	slot, id := g.lookup(name)
+	if id >= 0 {
+		return int(id)
+	}
+	id = int32(g.CitiesLen())
+	g.names = append(g.names, name...)
+	g.offsets = append(g.offsets, len(g.names))
+	for i := 0; i < g.slots; i++ {
+		g.roads = append(g.roads, -1)
+	}
+	for 64*len(g.oneWay) < len(g.roads) {
+		g.oneWay = append(g.oneWay, 0)
+		g.closed = append(g.closed, 0)
+	}
+	if id%64 == 0 {
+		g.destroyed = append(g.destroyed, 0)
+	}
+	g.index[slot] = id + 1
+	// keep the index at most half full
+	if 2*g.CitiesLen() > len(g.index) {
+		g.index = make([]int32, 2*len(g.index))
+		for city := 0; city < g.CitiesLen(); city++ {
+			slot, _ := g.lookup(g.name(city))
+			g.index[slot] = int32(city) + 1
+		}
+	}
+	return int(id)
+}
+
+// parseLine adds the city and the roads of a line of the .txt format
+func (g *Graph) parseLine(line []byte) error {
+	name, rest := nextField(line)
+	if name == nil {
+		return nil // skip empty lines
+	}
+	city := g.city(name)
+	for {
+		var word []byte
+		word, rest = nextField(rest)
+		if word == nil {
+			return nil
+		}
+		dir, dest, oneWay := splitRoad(word)
+		if dir == nil || len(dest) == 0 {
+			return fmt.Errorf("Invalid road %s from city %s", word, name)
+		}
+		slot, err := g.directions.parseSlot(dir)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(dest, name) {
+			return fmt.Errorf("City %s can't have a road to itself", dest)
+		}
+		err = g.addRoad(city, slot, g.city(dest), oneWay)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// addRoad adds a road, and its reverse road unless it is one-way, unless one
+// of the cities already has a road to another city in that direction or the
+// same road the other way
+func (g *Graph) addRoad(from int, slot int, to int, oneWay bool) error {
+	roads := [2][3]int{{from, slot, to}, {to, slot ^ 1, from}}
+	n := 2
+	if oneWay {
+		n = 1
+	}
+	for _, road := range roads[:n] {
+		i := g.slots*road[0] + road[1]
+		existing := int(g.roads[i])
+		if existing >= 0 && existing != road[2] {
+			return fmt.Errorf("City %s has roads %v to both %s and %s", g.name(road[0]),
+				compass[road[1]], g.name(existing), g.name(road[2]))
+		}
+		if existing >= 0 && g.isOneWay(i) != oneWay {
+			return fmt.Errorf("City %s has a one-way and a two-way road %v to %s", g.name(road[0]),
+				compass[road[1]], g.name(road[2]))
+		}
+	}
+	for _, road := range roads[:n] {
+		g.setRoad(road[0], road[1], road[2], oneWay, true)
+	}
+	return nil
+}
+
+// setRoad sets the road of a city in a slot, whatever was there, adding slots
+// to every city if the graph has fewer
+func (g *Graph) setRoad(from int, slot int, to int, oneWay bool, available bool) {
+	if slot >= g.slots {
+		g.resize(slot + 1)
+	}
+	i := g.slots*from + slot
+	g.roads[i] = int32(to)
+	setBit(g.oneWay, i, oneWay)
+	setBit(g.closed, i, !available)
+}
+
+// roadAt returns the destination of the road of a city in a slot, or -1, and
+// whether the road is available
+func (g *Graph) roadAt(id int, slot int) (int, bool) {
+	if slot < 0 || slot >= g.slots {
+		return -1, false
+	}
+	i := g.slots*id + slot
+	to := int(g.roads[i])
+	return to, to >= 0 && g.available(i)
+}
+
+// available checks if the road in a slot of the flat array is available,
+// which it is while it wasn't destroyed and neither of its cities was
+func (g *Graph) available(i int) bool {
+	to := int(g.roads[i])
+	return to >= 0 && g.closed[i/64]&(1<<(i%64)) == 0 && !g.IsDestroyed(i/g.slots) && !g.IsDestroyed(to)
+}
+
+// availableSlots returns the slots of the available roads of a city, in
+// order, and how many there are
+func (g *Graph) availableSlots(id int) ([len(compass)]int, int) {
+	var slots [len(compass)]int
+	n := 0
+	for slot := 0; slot < g.slots; slot++ {
+		if g.available(g.slots*id + slot) {
+			slots[n] = slot
+			n++
+		}
+	}
+	return slots, n
+}
+
+// isOneWay checks if the road in a slot of the flat array is one-way
+func (g *Graph) isOneWay(i int) bool {
+	return g.oneWay[i/64]&(1<<(i%64)) != 0
+}
+
+// setDirections sets the directions the roads of the graph can take. The
+// slots of the cities only grow, so the roads already in the graph stay.
+func (g *Graph) setDirections(set *DirectionSet) {
+	g.directions = set
+	switch {
+	case g.CitiesLen() == 0:
+		g.slots = set.slots
+	case set.slots > g.slots:
+		g.resize(set.slots)
+	}
+}
+
+// resize gives every city the given number of road slots
+func (g *Graph) resize(slots int) {
+	roads := make([]int32, slots*g.CitiesLen())
+	oneWay := make([]uint64, (len(roads)+63)/64)
+	closed := make([]uint64, len(oneWay))
+	for id := 0; id < g.CitiesLen(); id++ {
+		for slot := 0; slot < slots; slot++ {
+			j := slots*id + slot
+			roads[j] = -1
+			if slot < g.slots {
+				i := g.slots*id + slot
+				roads[j] = g.roads[i]
+				setBit(oneWay, j, g.isOneWay(i))
+				setBit(closed, j, g.closed[i/64]&(1<<(i%64)) != 0)
+			}
+		}
+	}
+	g.roads, g.oneWay, g.closed, g.slots = roads, oneWay, closed, slots
+}
+
+// setBit sets or clears a bit of a bitset
+func setBit(bits []uint64, i int, value bool) {
+	if value {
+		bits[i/64] |= 1 << (i % 64)
+	} else {
+		bits[i/64] &^= 1 << (i % 64)
+	}
+}
+
+// splitRoad splits a road of the .txt format in its direction and its
+// destination, which follows = for a two-way road and -> for a one-way road,
+// whichever comes first. The direction is nil if there is neither.
+func splitRoad(word []byte) ([]byte, []byte, bool) {
+	eq := bytes.IndexByte(word, '=')
+	arrow := bytes.Index(word, []byte("->"))
+	switch {
+	case arrow >= 0 && (eq < 0 || arrow < eq):
+		return word[:arrow], word[arrow+2:], true
+	case eq >= 0:
+		return word[:eq], word[eq+1:], false
+	default:
+		return nil, nil, false
+	}
+}
+
+// nextField returns the first field of the text, split by white space like
+// strings.Fields, and the text after it. The field is nil if there is none.
+func nextField(text []byte) ([]byte, []byte) {
+	start := -1
+	for i := 0; i < len(text); {
+		r, size := rune(text[i]), 1
+		if r >= utf8.RuneSelf {
+			r, size = utf8.DecodeRune(text[i:])
+		}
+		if unicode.IsSpace(r) {
+			if start >= 0 {
+				return text[start:i], text[i:]
+			}
+		} else if start < 0 {
+			start = i
+		}
+		i += size
+	}
+	if start < 0 {
+		return nil, nil
+	}
+	return text[start:], nil
+}
+
+// equalLower checks that the text in lower case is the given lower case word
+func equalLower(text []byte, word string) bool {
+	i := 0
+	for len(text) > 0 {
+		r, size := utf8.DecodeRune(text)
+		text = text[size:]
+		if i >= len(word) || unicode.ToLower(r) != rune(word[i]) {
+			return false
+		}
+		i++
+	}
+	return i == len(word)
+}
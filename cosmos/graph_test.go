@@ -0,0 +1,152 @@
+package cosmos
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const graphMap = `Foo north=Bar west=Baz south=Qu-ux
+Bar south=Foo west=Bee
+`
+
+func TestLoadGraph(t *testing.T) {
+	g, err := LoadGraph(strings.NewReader(graphMap))
+	require.Nil(t, err)
+	assert.Equal(t, 5, g.CitiesLen())
+	foo, err := g.CityID("Foo")
+	require.Nil(t, err)
+	assert.Equal(t, 0, foo)
+	bar, _ := g.CityID("Bar")
+	assert.Equal(t, "Bar", g.CityName(bar))
+	_, err = g.CityID("Nowhere")
+	assert.EqualError(t, err, "Couldn't find city Nowhere")
+
+	to, ok := g.Road(foo, North)
+	assert.True(t, ok)
+	assert.Equal(t, bar, to)
+	to, ok = g.Road(bar, South)
+	assert.True(t, ok)
+	assert.Equal(t, foo, to)
+	_, ok = g.Road(foo, East)
+	assert.False(t, ok)
+	assert.Equal(t, 3, g.AvailableRoads(foo))
+
+	g.Destroy(foo)
+	assert.True(t, g.IsDestroyed(foo))
+	assert.False(t, g.IsDestroyed(bar))
+	_, ok = g.Road(bar, South)
+	assert.False(t, ok)
+	assert.Equal(t, 1, g.AvailableRoads(bar))
+	var out bytes.Buffer
+	_, err = g.WriteTo(&out)
+	require.Nil(t, err)
+	assert.Equal(t, "Bar west=Bee\nBaz\nQu-ux\nBee east=Bar\n", out.String())
+}
+
+func TestLoadGraphErrors(t *testing.T) {
+	for input, msg := range map[string]string{
+		"Foo north":                    "Line 1: Invalid road north from city Foo",
+		"Foo north=":                   "Line 1: Invalid road north= from city Foo",
+		"Foo upward=Bar":               "Line 1: String upward is not a valid direction",
+		"Foo up=Bar":                   "Line 1: Direction up is not in direction set 4",
+		"Foo north=Foo":                "Line 1: City Foo can't have a road to itself",
+		"Foo north=Bar north=Baz":      "Line 1: City Foo has roads north to both Bar and Baz",
+		"Foo north=Bar\nBaz north=Bar": "Line 2: City Bar has roads south to both Foo and Baz",
+	} {
+		_, err := LoadGraph(strings.NewReader(input))
+		assert.EqualError(t, err, msg, input)
+	}
+}
+
+func TestLoadGraphLongLine(t *testing.T) {
+	// a line longer than the buffer of the reader
+	name := strings.Repeat("x", 200000)
+	g, err := LoadGraph(strings.NewReader("Foo east=" + name + "\n\n" + name + " north=Bar"))
+	require.Nil(t, err)
+	assert.Equal(t, 3, g.CitiesLen())
+	id, err := g.CityID(name)
+	require.Nil(t, err)
+	to, ok := g.Road(id, North)
+	assert.True(t, ok)
+	assert.Equal(t, "Bar", g.CityName(to))
+}
+
+func TestGraphMap(t *testing.T) {
+	var text strings.Builder
+	// enough cities to grow the index a few times
+	for i := 0; i < 1000; i++ {
+		text.WriteString("City" + strconv.Itoa(i) + " east=City" + strconv.Itoa(i+1) + "\n")
+	}
+	g, err := LoadGraph(strings.NewReader(text.String()))
+	require.Nil(t, err)
+	assert.Equal(t, 1001, g.CitiesLen())
+	g.Destroy(500)
+
+	// the map keeps its cities and roads in the graph, and only builds the
+	// cities it is asked for
+	m := g.Map()
+	assert.Same(t, g, m.Graph())
+	assert.Equal(t, 1001, m.CitiesLen())
+	assert.Nil(t, CheckInvariants(m))
+	for id := 0; id < g.CitiesLen(); id++ {
+		assert.Nil(t, m.cities[id].Load())
+	}
+	for id := 0; id < g.CitiesLen(); id++ {
+		assert.Equal(t, g.CityName(id), m.CityName(id))
+		city, err := m.GetCity(g.CityName(id))
+		require.Nil(t, err)
+		assert.Same(t, city, m.city(id))
+		assert.Equal(t, g.IsDestroyed(id), city.IsDestroyed())
+		assert.Equal(t, g.AvailableRoads(id), city.GetRoads().AvailableRoads())
+	}
+	city, _ := m.GetCity("City499")
+	road, _ := city.GetRoad(East.IntValue())
+	assert.False(t, road.IsAvailable())
+
+	// roads destroyed through the map are destroyed in the graph
+	city, _ = m.GetCity("City10")
+	road, _ = city.GetRoad(East.IntValue())
+	require.Nil(t, road.Destroy())
+	_, ok := g.Road(10, East)
+	assert.False(t, ok)
+	_, ok = g.Road(11, West)
+	assert.True(t, ok)
+	assert.Error(t, road.Destroy())
+}
+
+func TestMapSetCity(t *testing.T) {
+	// cities keep their roads until they are set in a map, which takes the
+	// cities they lead to along
+	foo, bar, baz := NewCity("Foo"), NewCity("Bar"), NewCity("Baz")
+	require.Nil(t, foo.AddRoad(NewRoad(foo, East, bar)))
+	require.Nil(t, foo.AddRoad(NewOneWayRoad(foo, Up, baz)))
+	m := CreateMap()
+	m.SetCity(foo)
+	assert.Equal(t, 3, m.CitiesLen())
+	assert.Equal(t, []string{"Foo", "Bar", "Baz"}, []string{m.CityName(0), m.CityName(1), m.CityName(2)})
+	city, err := m.GetCity("Bar")
+	require.Nil(t, err)
+	assert.Same(t, bar, city)
+	m.SetCity(bar)
+	assert.Equal(t, 3, m.CitiesLen())
+
+	// a road outside the directions of the map gives every city its slot
+	road, err := foo.GetRoad(Up.IntValue())
+	require.Nil(t, err)
+	assert.Same(t, baz, road.Destination())
+	assert.True(t, road.IsOneWay())
+	assert.Len(t, baz.GetIncoming(), 1)
+	_, ok := m.Graph().Road(0, East)
+	assert.True(t, ok)
+	_, ok = m.Graph().Road(1, West)
+	assert.False(t, ok)
+	var out bytes.Buffer
+	_, err = m.WriteTo(&out)
+	require.Nil(t, err)
+	assert.Equal(t, "Foo east=Bar up->Baz\nBar\nBaz\n", out.String())
+}
@@ -0,0 +1,78 @@
+package cosmos
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ========== Invariants ==========
+
+// CheckInvariants verifies that the state of the map is consistent:
+//   - every living alien is in the set of aliens of the city it stands on
+//   - no alien is in a destroyed city
+//   - every available two-way road has an available reverse road
+//   - the living aliens of the map are the union of the aliens of its cities
+//
+// The roads from and to destroyed cities need no check, as the map never
+// takes them as available.
+//
+// It returns an error listing every broken invariant, or nil.
+func CheckInvariants(m *Map) error {
+	var errs []error
+	for _, id := range m.Aliens.IDs() {
+		alien := m.Aliens[id]
+		if !alien.IsAlive() {
+			continue
+		}
+		city := alien.GetPosition()
+		if city == nil {
+			errs = append(errs, fmt.Errorf("Alien %v hasn't been placed", id))
+			continue
+		}
+		if city.aliens[id] != alien {
+			errs = append(errs, fmt.Errorf("Alien %v is not in the aliens of its city %v", id, city.Name()))
+		}
+	}
+	g := m.graph
+	for id := 0; id < g.CitiesLen(); id++ {
+		name := g.CityName(id)
+		// only the cities some alien got to have aliens
+		if city := m.cities[id].Load(); city != nil {
+			for _, alienID := range city.aliens.IDs() {
+				alien := city.aliens[alienID]
+				switch {
+				case city.IsDestroyed():
+					errs = append(errs, fmt.Errorf("Alien %v is in destroyed city %v", alienID, name))
+				case !alien.IsAlive():
+					errs = append(errs, fmt.Errorf("Dead alien %v is in city %v", alienID, name))
+				case m.Aliens[alienID] != alien:
+					errs = append(errs, fmt.Errorf("Alien %v in city %v is not in the aliens of the map", alienID, name))
+				case alien.GetPosition() != city:
+					errs = append(errs, fmt.Errorf("Alien %v is in city %v but stands on another city", alienID, name))
+				}
+			}
+		}
+		for slot := 0; slot < g.slots; slot++ {
+			to, ok := g.roadAt(id, slot)
+			if !ok || g.isOneWay(g.slots*id+slot) {
+				continue
+			}
+			reverse, available := g.roadAt(to, slot^1)
+			if reverse != id || !available || g.isOneWay(g.slots*to+(slot^1)) {
+				errs = append(errs, fmt.Errorf("Road %v from %v to %v has no available reverse road", compass[slot], name, g.CityName(to)))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// cityNames returns the sorted names of the cities in the map
+func (m *Map) cityNames() []string {
+	var names = make([]string, 0, m.CitiesLen())
+	for id := 0; id < m.CitiesLen(); id++ {
+		names = append(names, m.CityName(id))
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,152 @@
+package cosmos
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ========== Metrics ==========
+
+// Metrics collects statistics of the simulations run by the engine and
+// exposes them in the Prometheus text exposition format
+type Metrics struct {
+	mu        sync.Mutex
+	started   uint64
+	finished  uint64
+	failed    uint64
+	fights    uint64
+	destroyed uint64
+	active    int64
+	rounds    *histogram // rounds until the battle ended
+	duration  *histogram // wall time of each simulation in seconds
+}
+
+// DefaultMetrics collects the metrics of every simulation that does not set
+// its own
+var DefaultMetrics = NewMetrics()
+
+// NewMetrics creates an empty set of metrics
+func NewMetrics() *Metrics {
+	return &Metrics{
+		rounds:   newHistogram(1, 10, 100, 1000, 10000),
+		duration: newHistogram(0.001, 0.01, 0.1, 1, 10, 60),
+	}
+}
+
+// start records a simulation that started running
+func (m *Metrics) start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.started++
+	m.active++
+}
+
+// finish records a simulation that ended after the given rounds
+func (m *Metrics) finish(rounds int, elapsed time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.active--
+	if err != nil {
+		m.failed++
+		return
+	}
+	m.finished++
+	m.rounds.observe(float64(rounds))
+	m.duration.observe(elapsed.Seconds())
+}
+
+// fight records a fight between aliens
+func (m *Metrics) fight() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fights++
+}
+
+// destroy records a city destroyed by a fight
+func (m *Metrics) destroy() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.destroyed++
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := &exposition{w: w}
+	e.metric("aliens_simulations_started_total", "counter", "Simulations started.", float64(m.started))
+	e.metric("aliens_simulations_finished_total", "counter", "Simulations that ran to completion.", float64(m.finished))
+	e.metric("aliens_simulations_failed_total", "counter", "Simulations that ended with an error.", float64(m.failed))
+	e.metric("aliens_simulations_active", "gauge", "Simulations currently running.", float64(m.active))
+	e.metric("aliens_fights_total", "counter", "Fights between aliens.", float64(m.fights))
+	e.metric("aliens_cities_destroyed_total", "counter", "Cities destroyed by fights.", float64(m.destroyed))
+	e.histogram("aliens_simulation_rounds", "Rounds simulated until the battle ended.", m.rounds)
+	e.histogram("aliens_simulation_duration_seconds", "Wall time of each simulation.", m.duration)
+	return e.n, e.err
+}
+
+// ----- Unexported functions -----
+
+// histogram counts observations in cumulative buckets
+type histogram struct {
+	bounds []float64
+	counts []uint64 // observations in each bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+func newHistogram(bounds ...float64) *histogram {
+	return &histogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)),
+	}
+}
+
+func (h *histogram) observe(v float64) {
+	h.count++
+	h.sum += v
+	for i, bound := range h.bounds {
+		if v <= bound {
+			h.counts[i]++
+			return
+		}
+	}
+}
+
+// exposition writes metrics and keeps the first error found
+type exposition struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (e *exposition) printf(format string, args ...interface{}) {
+	if e.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(e.w, format, args...)
+	e.n += int64(n)
+	e.err = err
+}
+
+func (e *exposition) metric(name, kind, help string, value float64) {
+	e.printf("# HELP %s %s\n# TYPE %s %s\n%s %s\n", name, help, name, kind, name, formatFloat(value))
+}
+
+func (e *exposition) histogram(name, help string, h *histogram) {
+	e.printf("# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		e.printf("%s_bucket{le=\"%s\"} %d\n", name, formatFloat(bound), cumulative)
+	}
+	e.printf("%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	e.printf("%s_sum %s\n%s_count %d\n", name, formatFloat(h.sum), name, h.count)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,99 @@
+package cosmos
+
+import "fmt"
+
+// ========== Observers ==========
+
+// While a simulation runs, its goroutine is the only one that changes the map,
+// and it holds the lock of the simulation for every change: a move, a fight
+// or, in the parallel engine, the moves of a round. Observers in other
+// goroutines read the state through the simulation, which holds the read lock
+// while they do, so they always see the map between two changes. Events are
+// emitted without the lock, so listeners can read the state as well.
+
+// CityState is a copy of the state of a city at some point of a battle
+type CityState struct {
+	Name      string               `json:"name"`
+	Destroyed bool                 `json:"destroyed"`
+	Aliens    []int                `json:"aliens"`            // ids of the aliens in the city
+	Roads     map[Direction]string `json:"roads"`             // destination of each available road
+	OneWay    []Direction          `json:"one_way,omitempty"` // directions of the roads above that are one-way
+}
+
+// AlienState is a copy of the state of an alien at some point of a battle
+type AlienState struct {
+	ID    int    `json:"id"`
+	Alive bool   `json:"alive"`
+	City  string `json:"city"` // city where the alien stands, or where it died
+}
+
+// View calls fn with the map of the simulation while no alien moves, so the
+// map can be read from any goroutine during a run. fn must not change the
+// map or keep any reference to it after it returns.
+func (s *Simulation) View(fn func(m *Map)) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	fn(s.m)
+}
+
+// Progress returns the aliens left and the current round of the simulation
+func (s *Simulation) Progress() (int, int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.aliensLeft, s.round
+}
+
+// City returns the current state of a city of the simulation
+func (s *Simulation) City(name string) (CityState, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	city, err := s.m.GetCity(name)
+	if err != nil {
+		return CityState{}, err
+	}
+	return city.state(), nil
+}
+
+// Alien returns the current state of an alien of the simulation
+func (s *Simulation) Alien(id int) (AlienState, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	alien, err := s.m.Aliens.Get(id)
+	if err != nil {
+		return AlienState{}, err
+	}
+	st := AlienState{ID: alien.id, Alive: alien.alive}
+	if alien.position == nil {
+		return st, fmt.Errorf("Alien %v hasn't been placed", id)
+	}
+	st.City = alien.position.name
+	return st, nil
+}
+
+// ----- Unexported functions -----
+
+// state copies the state of the city
+func (city *City) state() CityState {
+	st := graphState(city.m.graph, city.id)
+	st.Aliens = city.aliens.IDs()
+	return st
+}
+
+// graphState copies the state of a city of a graph, without its aliens
+func graphState(g *Graph, id int) CityState {
+	st := CityState{
+		Name:      g.CityName(id),
+		Destroyed: g.IsDestroyed(id),
+		Aliens:    []int{},
+		Roads:     make(map[Direction]string),
+	}
+	for slot := 0; slot < g.slots; slot++ {
+		if to, ok := g.roadAt(id, slot); ok {
+			st.Roads[compass[slot]] = g.CityName(to)
+			if g.isOneWay(g.slots*id + slot) {
+				st.OneWay = append(st.OneWay, compass[slot])
+			}
+		}
+	}
+	return st
+}
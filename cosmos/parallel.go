@@ -0,0 +1,273 @@
+package cosmos
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ========== Parallel engine ==========
+
+// The parallel engine splits the cities of the map in regions, one for each
+// worker. Every round, the living aliens choose their roads at the same time,
+// from the state of the map at the start of the round, and each worker moves
+// the aliens of its region. Aliens that move to another region are handed
+// over once every worker is done. Then every city where aliens arrived fights
+// if it holds enough aliens.
+//
+// The road an alien takes only depends on the seed, the round and its id, and
+// the fights on where the aliens end up, so a seed reproduces the same battle
+// whatever the number of workers. The battle is not the one of the sequential
+// engine, where aliens move one at a time and fight as soon as they meet.
+
+// region holds the state of a worker of the parallel engine
+type region struct {
+	aliens  []*Alien         // living aliens in the cities of the region
+	outbox  [][]parallelMove // moves of the round to the cities of each region
+	moves   []parallelMove   // moves of the round that started in the region
+	arrived []*City          // cities of the region where aliens arrived in the round
+}
+
+// parallelMove is an alien taking a road during a round
+type parallelMove struct {
+	alien *Alien
+	from  *City
+	slot  int   // slot of the road taken
+	to    *City // destination of the road
+}
+
+// partition splits the cities in regions of consecutive city ids, which keeps
+// most of the roads of a map read from a file inside a region
+type partition struct {
+	regions []*region
+	cities  int // cities of the map
+}
+
+// newPartition splits the map in the given number of regions and places the
+// living aliens in the regions of their cities
+func newPartition(m *Map, workers int) *partition {
+	p := &partition{
+		regions: make([]*region, workers),
+		cities:  m.CitiesLen(),
+	}
+	for i := range p.regions {
+		p.regions[i] = &region{outbox: make([][]parallelMove, workers)}
+	}
+	for _, id := range m.Aliens.IDs() {
+		alien := m.Aliens[id]
+		if alien.IsAlive() && alien.position != nil {
+			r := p.regionOf(alien.position)
+			r.aliens = append(r.aliens, alien)
+		}
+	}
+	return p
+}
+
+// regionOf returns the region of a city
+func (p *partition) regionOf(city *City) *region {
+	return p.regions[p.regionID(city)]
+}
+
+// regionID returns the index of the region of a city
+func (p *partition) regionID(city *City) int {
+	return city.id * len(p.regions) / p.cities
+}
+
+// each runs fn for every region, in parallel, and waits for all of them
+func (p *partition) each(fn func(i int, r *region) error) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(p.regions))
+	for i, r := range p.regions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = fn(i, r)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Simulation) runParallel() (int, int, error) {
+	p := newPartition(s.m, s.cfg.Workers)
+	start := s.round
+	for s.aliensLeft > 0 && s.round < s.cfg.MaxRounds {
+		if s.round%1000 == 0 {
+			s.logger.Info("Simulating round...", "round", s.round, "aliens", s.aliensLeft, "workers", s.cfg.Workers)
+		}
+		err := s.beforeRound(start)
+		if err != nil {
+			return -1, -1, err
+		}
+		err = s.playParallelRound(p)
+		if err != nil {
+			return -1, -1, err
+		}
+		if s.cfg.CheckInvariants {
+			err = CheckInvariants(s.m)
+			if err != nil {
+				return -1, -1, fmt.Errorf("Invariants broken after round %v: %w", s.round, err)
+			}
+		}
+		s.nextRound()
+	}
+	return s.aliensLeft, s.round, nil
+}
+
+// playParallelRound moves every living alien once and then resolves the fights
+func (s *Simulation) playParallelRound(p *partition) error {
+	err := s.moveParallel(p)
+	if err != nil {
+		return err
+	}
+	if len(s.listeners) > 0 {
+		s.emitParallelMoves(p)
+	}
+	// fights destroy roads of the neighbour cities, which may be in other
+	// regions, so they are resolved one at a time in the order of the cities
+	var fights []*City
+	for _, r := range p.regions {
+		for _, city := range r.arrived {
+			if !city.IsDestroyed() && city.aliens.Len() >= s.cfg.FightThreshold {
+				fights = append(fights, city)
+			}
+		}
+	}
+	sort.Slice(fights, func(a, b int) bool { return fights[a].id < fights[b].id })
+	for i, city := range fights {
+		if i > 0 && city == fights[i-1] {
+			continue
+		}
+		var aliensInCity = city.aliens.IDs()
+		s.emit(Event{Type: FightEvent, Round: s.round, Aliens: aliensInCity, City: city.name})
+		err := s.fight(aliensInCity[0], city, len(aliensInCity))
+		if err != nil {
+			return err
+		}
+		s.emit(Event{Type: DestroyEvent, Round: s.round, Aliens: aliensInCity, City: city.name})
+	}
+	return nil
+}
+
+// moveParallel moves the aliens of every region and hands over the aliens
+// that moved to other regions, holding the lock of the simulation
+func (s *Simulation) moveParallel(p *partition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// every region moves its aliens, keeping the moves to other regions
+	err := p.each(func(i int, r *region) error {
+		living := r.aliens[:0]
+		r.moves = r.moves[:0]
+		r.arrived = r.arrived[:0]
+		for _, alien := range r.aliens {
+			if !alien.alive {
+				continue
+			}
+			from := alien.position
+			slot := s.parallelRoad(alien, from)
+			if slot < 0 {
+				living = append(living, alien)
+				continue
+			}
+			dest, _ := s.m.graph.roadAt(from.id, slot)
+			mv := parallelMove{alien: alien, from: from, slot: slot, to: s.m.city(dest)}
+			r.moves = append(r.moves, mv)
+			to := p.regionID(mv.to)
+			if to != i {
+				err := from.RemoveAlien(alien.id)
+				if err != nil {
+					return err
+				}
+				r.outbox[to] = append(r.outbox[to], mv)
+				continue
+			}
+			_, err := move(alien, slot)
+			if err != nil {
+				return err
+			}
+			living = append(living, alien)
+			r.arrived = append(r.arrived, mv.to)
+		}
+		clear(r.aliens[len(living):])
+		r.aliens = living
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// every region takes in the aliens that arrived from other regions
+	err = p.each(func(i int, r *region) error {
+		for _, source := range p.regions {
+			for _, mv := range source.outbox[i] {
+				dest := mv.to
+				err := dest.AddAlien(mv.alien)
+				if err != nil {
+					return err
+				}
+				mv.alien.position = dest
+				r.aliens = append(r.aliens, mv.alien)
+				r.arrived = append(r.arrived, dest)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, source := range p.regions {
+		for i := range source.outbox {
+			clear(source.outbox[i])
+			source.outbox[i] = source.outbox[i][:0]
+		}
+	}
+	return nil
+}
+
+// parallelRoad selects the slot of the road an alien takes in a round of the
+// parallel engine, or -1 if its city has no available roads. The random
+// strategy draws from a number derived from the seed, the round and the id of
+// the alien, so the choice doesn't depend on the order the aliens move in.
+func (s *Simulation) parallelRoad(alien *Alien, city *City) int {
+	available, n := s.m.graph.availableSlots(city.id)
+	switch {
+	case n == 0:
+		return -1
+	case n == 1 || s.cfg.Strategy == FirstStrategy:
+		return available[0]
+	default:
+		draw := mix(uint64(s.seed) ^ mix(uint64(s.round)<<32^uint64(alien.id)))
+		return available[draw%uint64(n)]
+	}
+}
+
+// emitParallelMoves emits the moves of the round in the order of the aliens
+func (s *Simulation) emitParallelMoves(p *partition) {
+	var moves []parallelMove
+	for _, r := range p.regions {
+		moves = append(moves, r.moves...)
+	}
+	sort.Slice(moves, func(a, b int) bool { return moves[a].alien.id < moves[b].alien.id })
+	for _, mv := range moves {
+		s.emit(Event{
+			Type:      MoveEvent,
+			Round:     s.round,
+			Aliens:    []int{mv.alien.id},
+			City:      mv.to.name,
+			From:      mv.from.name,
+			Direction: compass[mv.slot],
+		})
+	}
+}
+
+// mix scrambles the bits of a number (the finalizer of SplitMix64)
+func mix(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
@@ -0,0 +1,120 @@
+package cosmos
+
+import "fmt"
+
+// ========== Paths ==========
+
+// Path is a shortest route between two cities over the available roads, which
+// takes one-way roads only in their direction
+type Path struct {
+	Cities     []string    `json:"cities"`     // from the first city to the last one
+	Directions []Direction `json:"directions"` // of the road taken from each city but the last
+	OneWay     []bool      `json:"one_way"`    // whether the road taken from each city but the last is one-way
+}
+
+// Len returns the number of roads of the path
+func (p Path) Len() int {
+	return len(p.Directions)
+}
+
+// ShortestPath finds a path with the fewest roads between two cities, taking
+// only the available roads between cities that were not destroyed. The same
+// map always gives the same path. To query a running simulation call it
+// inside Simulation.View.
+func ShortestPath(m *Map, from string, to string) (Path, error) {
+	n := newNetwork(m, false)
+	src, err := n.cityID(from)
+	if err != nil {
+		return Path{}, err
+	}
+	dst, err := n.cityID(to)
+	if err != nil {
+		return Path{}, err
+	}
+	dist := make([]int32, len(n.cities))
+	prev := make([]int32, len(n.cities))
+	n.bfs(src, dist, prev, nil)
+	if dist[dst] < 0 {
+		return Path{}, fmt.Errorf("No road leads from %v to %v", from, to)
+	}
+	cities := make([]string, dist[dst]+1)
+	directions := make([]Direction, dist[dst])
+	oneWay := make([]bool, dist[dst])
+	for city, i := dst, dist[dst]; i >= 0; city, i = int(prev[city]), i-1 {
+		cities[i] = n.name(int32(city))
+		if i > 0 {
+			slot := n.slot(int(prev[city]), city)
+			directions[i-1] = compass[slot]
+			oneWay[i-1] = n.graph.isOneWay(n.graph.slots*int(n.cities[prev[city]]) + slot)
+		}
+	}
+	return Path{Cities: cities, Directions: directions, OneWay: oneWay}, nil
+}
+
+// Reachable returns the cities that can be reached from a city over the
+// available roads, itself included, the closest first
+func Reachable(m *Map, from string) ([]string, error) {
+	n := newNetwork(m, false)
+	src, err := n.cityID(from)
+	if err != nil {
+		return nil, err
+	}
+	queue := n.bfs(src, make([]int32, len(n.cities)), nil, nil)
+	names := make([]string, len(queue))
+	for i, city := range queue {
+		names[i] = n.name(city)
+	}
+	return names, nil
+}
+
+// DistanceMatrix returns the number of roads of the shortest path from each of
+// the given cities to each of them, -1 if there is none. With one-way roads
+// the way back may be longer.
+func DistanceMatrix(m *Map, names []string) ([][]int, error) {
+	n := newNetwork(m, false)
+	ids := make([]int, len(names))
+	for i, name := range names {
+		id, err := n.cityID(name)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	matrix := make([][]int, len(names))
+	dist := make([]int32, len(n.cities))
+	var queue []int32
+	for i, src := range ids {
+		queue = n.bfs(src, dist, nil, queue)
+		matrix[i] = make([]int, len(names))
+		for j, dst := range ids {
+			matrix[i][j] = int(dist[dst])
+		}
+	}
+	return matrix, nil
+}
+
+// ----- Unexported functions -----
+
+// cityID returns the number of a city in the network
+func (n *network) cityID(name string) (int, error) {
+	city := n.graph.lookupString(name)
+	if city < 0 {
+		return 0, fmt.Errorf("Couldn't find city %v", name)
+	}
+	id := n.ids[city]
+	if id < 0 {
+		return 0, fmt.Errorf("City %v was destroyed", name)
+	}
+	return int(id), nil
+}
+
+// slot returns the slot of the first available road from a city to another
+// one
+func (n *network) slot(from int, to int) int {
+	for slot := 0; slot < n.graph.slots; slot++ {
+		if dest, ok := n.graph.roadAt(int(n.cities[from]), slot); ok && dest == int(n.cities[to]) {
+			return slot
+		}
+	}
+	return -1
+}
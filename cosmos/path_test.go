@@ -0,0 +1,81 @@
+package cosmos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShortestPath(t *testing.T) {
+	m := textMap(t, "Foo north=Bar east=Baz\nBar east=Qux\nBaz south=Qux\nQux east=Zed\nBee west=Ann\n")
+	path, err := ShortestPath(m, "Foo", "Zed")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"Foo", "Bar", "Qux", "Zed"}, path.Cities)
+	assert.Equal(t, []Direction{North, East, East}, path.Directions)
+	assert.Equal(t, []bool{false, false, false}, path.OneWay)
+	assert.Equal(t, 3, path.Len())
+
+	path, err = ShortestPath(m, "Foo", "Foo")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"Foo"}, path.Cities)
+	assert.Equal(t, 0, path.Len())
+
+	_, err = ShortestPath(m, "Foo", "Ann")
+	assert.EqualError(t, err, "No road leads from Foo to Ann")
+	_, err = ShortestPath(m, "Foo", "Nowhere")
+	assert.EqualError(t, err, "Couldn't find city Nowhere")
+
+	// with Bar destroyed the path goes the other way round
+	bar, err := m.GetCity("Bar")
+	require.Nil(t, err)
+	require.Nil(t, destroy(bar))
+	path, err = ShortestPath(m, "Foo", "Zed")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"Foo", "Baz", "Qux", "Zed"}, path.Cities)
+	assert.Equal(t, []Direction{East, South, East}, path.Directions)
+	_, err = ShortestPath(m, "Bar", "Zed")
+	assert.EqualError(t, err, "City Bar was destroyed")
+}
+
+func TestReachable(t *testing.T) {
+	m := textMap(t, "Foo north=Bar east=Baz\nBar east=Qux\nBaz south=Qux\nQux east=Zed\nBee west=Ann\n")
+	cities, err := Reachable(m, "Zed")
+	require.Nil(t, err)
+	assert.Equal(t, "Zed", cities[0])
+	assert.Equal(t, "Qux", cities[1])
+	assert.ElementsMatch(t, []string{"Foo", "Bar", "Baz", "Qux", "Zed"}, cities)
+
+	cities, err = Reachable(m, "Ann")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"Ann", "Bee"}, cities)
+
+	matrix, err := DistanceMatrix(m, []string{"Foo", "Zed", "Ann"})
+	require.Nil(t, err)
+	assert.Equal(t, [][]int{{0, 3, -1}, {3, 0, -1}, {-1, -1, 0}}, matrix)
+	_, err = DistanceMatrix(m, []string{"Foo", "Nowhere"})
+	assert.Error(t, err)
+}
+
+func TestShortestPathOneWay(t *testing.T) {
+	// a river flows east from Foo to Bar, and the way back goes round
+	m := textMap(t, "Foo east->Bar\nBar south=Baz\nBaz west=Qux\nQux north=Foo\n")
+	path, err := ShortestPath(m, "Bar", "Foo")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"Bar", "Baz", "Qux", "Foo"}, path.Cities)
+	assert.Equal(t, []bool{false, false, false}, path.OneWay)
+	path, err = ShortestPath(m, "Qux", "Bar")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"Qux", "Foo", "Bar"}, path.Cities)
+	assert.Equal(t, []bool{false, true}, path.OneWay)
+	matrix, err := DistanceMatrix(m, []string{"Foo", "Bar"})
+	require.Nil(t, err)
+	assert.Equal(t, [][]int{{0, 1}, {3, 0}}, matrix)
+
+	m = textMap(t, "Foo east->Bar\n")
+	_, err = ShortestPath(m, "Bar", "Foo")
+	assert.EqualError(t, err, "No road leads from Bar to Foo")
+	cities, err := Reachable(m, "Foo")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"Foo", "Bar"}, cities)
+}
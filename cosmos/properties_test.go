@@ -0,0 +1,167 @@
+package cosmos_test
+
+import (
+	"io"
+	"log/slog"
+	"strconv"
+	"testing"
+
+	"github.com/fedekunze/alien_task/cosmos"
+	"pgregory.net/rapid"
+)
+
+// The property tests run the simulation on random maps and placements. When a
+// property fails, rapid shrinks the case to a minimal map before reporting it.
+
+var directions = []cosmos.Direction{cosmos.North, cosmos.South, cosmos.East, cosmos.West}
+
+// battle is a random map with its aliens placed
+type battle struct {
+	m      *cosmos.Map
+	aliens int
+}
+
+// drawBattle draws a map of up to 8 cities, with roads that always go both
+// ways, and up to 12 aliens placed in any of its cities
+func drawBattle(t *rapid.T) battle {
+	m := cosmos.CreateMap()
+	total := rapid.IntRange(1, 8).Draw(t, "cities")
+	cities := make([]*cosmos.City, total)
+	for i := range cities {
+		cities[i] = cosmos.NewCity("City" + strconv.Itoa(i))
+		m.SetCity(cities[i])
+	}
+	for i, city := range cities {
+		for _, dir := range directions {
+			// 0 leaves the road out, so failing cases shrink to maps
+			// with fewer roads
+			to := rapid.IntRange(0, total).Draw(t, "road "+city.Name()+" "+string(dir))
+			j := to - 1
+			if j < 0 || j == i {
+				continue
+			}
+			road := cosmos.NewRoad(city, dir, cities[j])
+			reverse := road.OppositeDirection()
+			if free(city, dir) && free(cities[j], reverse) {
+				city.AddRoad(road)
+				cities[j].AddRoad(cosmos.NewRoad(cities[j], reverse, city))
+			}
+		}
+	}
+	aliens := rapid.IntRange(0, 12).Draw(t, "aliens")
+	for i := 0; i < aliens; i++ {
+		city := cities[rapid.IntRange(0, total-1).Draw(t, "alien "+strconv.Itoa(i))]
+		alien := cosmos.NewAlien(i, city)
+		city.AddAlien(alien)
+		m.Aliens.Set(i, alien)
+	}
+	return battle{m: m, aliens: aliens}
+}
+
+// free checks that the city has no road in the direction yet
+func free(city *cosmos.City, dir cosmos.Direction) bool {
+	road, _ := city.GetRoad(dir.IntValue())
+	return road == nil
+}
+
+// drawRules draws the rules of a short battle
+func drawRules(t *rapid.T) cosmos.Config {
+	return cosmos.Config{
+		Seed:           rapid.Int64Range(1, 1<<32).Draw(t, "seed"),
+		MaxRounds:      rapid.IntRange(0, 50).Draw(t, "max rounds"),
+		Strategy:       rapid.SampledFrom([]cosmos.Strategy{cosmos.RandomStrategy, cosmos.FirstStrategy}).Draw(t, "strategy"),
+		FightThreshold: rapid.IntRange(2, 4).Draw(t, "fight threshold"),
+		Workers:        rapid.IntRange(0, 3).Draw(t, "workers"),
+	}
+}
+
+// alive counts the living aliens of the map
+func alive(m *cosmos.Map) int {
+	count := 0
+	for _, alien := range m.Aliens {
+		if alien.IsAlive() {
+			count++
+		}
+	}
+	return count
+}
+
+func TestSimulationProperties(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		b := drawBattle(t)
+		rules := drawRules(t)
+		sim := cosmos.NewSimulation(b.m, b.aliens)
+		if err := sim.SetConfig(rules); err != nil {
+			t.Fatal(err)
+		}
+		sim.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+		sim.SetMetrics(cosmos.NewMetrics())
+
+		aliensLeft := b.aliens
+		fights := 0
+		destroyed := make(map[string]bool)
+		sim.Subscribe(func(e cosmos.Event) {
+			if e.Round >= rules.MaxRounds {
+				t.Fatalf("%v event in round %v, after the limit of %v rounds", e.Type, e.Round, rules.MaxRounds)
+			}
+			if left := alive(b.m); left > aliensLeft {
+				t.Fatalf("aliens left went up from %v to %v after a %v event", aliensLeft, left, e.Type)
+			} else {
+				aliensLeft = left
+			}
+			switch e.Type {
+			case cosmos.MoveEvent:
+				if destroyed[e.City] {
+					t.Fatalf("alien %v moved into destroyed city %v", e.Aliens[0], e.City)
+				}
+			case cosmos.FightEvent:
+				fights++
+			case cosmos.DestroyEvent:
+				destroyed[e.City] = true
+			}
+		})
+
+		left, rounds, err := sim.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rounds > rules.MaxRounds {
+			t.Fatalf("ran %v rounds, over the limit of %v", rounds, rules.MaxRounds)
+		}
+		if left != alive(b.m) || left > b.aliens {
+			t.Fatalf("reported %v aliens left, %v are alive out of %v", left, alive(b.m), b.aliens)
+		}
+		destroyedCities := 0
+		for i := 0; i < b.m.CitiesLen(); i++ {
+			city, _ := b.m.GetCity(b.m.CityName(i))
+			if city.IsDestroyed() {
+				destroyedCities++
+				if city.CountAliens() > 0 {
+					t.Fatalf("destroyed city %v hosts %v aliens", city.Name(), city.CountAliens())
+				}
+			}
+		}
+		if destroyedCities != fights || len(destroyed) != fights {
+			t.Fatalf("%v fights destroyed %v cities", fights, destroyedCities)
+		}
+		if err := cosmos.CheckInvariants(b.m); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestSimulateProperties(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		b := drawBattle(t)
+		left, rounds, err := cosmos.Simulate(b.m, b.aliens)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rounds > cosmos.DefaultConfig().MaxRounds {
+			t.Fatalf("ran %v rounds, over the limit of %v", rounds, cosmos.DefaultConfig().MaxRounds)
+		}
+		if left < 0 || left > b.aliens {
+			t.Fatalf("%v aliens left out of %v", left, b.aliens)
+		}
+	})
+}
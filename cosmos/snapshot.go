@@ -0,0 +1,232 @@
+package cosmos
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"sort"
+)
+
+// ========== Snapshots ==========
+
+// Snapshot is a copy of the state of a simulation: the cities destroyed, the
+// roads available, where every alien is, the round and the state of the random
+// moves. The cities and roads are copied as the bitsets of the map, which
+// take a few bits for each of them. It never changes, so it can be kept to
+// rewind the simulation later.
+type Snapshot struct {
+	round      int
+	aliensLeft int
+	seed       int64
+	rngState   uint64          // state of the source of the random moves
+	graph      *Graph          // cities and roads of the map
+	cities     int             // cities of the map
+	destroyed  []uint64        // destroyed cities of the map
+	closed     []uint64        // roads of the map destroyed on their own
+	aliens     []alienSnapshot // sorted by id
+}
+
+// alienSnapshot is the state of an alien in a snapshot
+type alienSnapshot struct {
+	id    int
+	city  int // id of the city of the alien, or -1 if it wasn't placed
+	alive bool
+}
+
+// Snapshot copies the current state of the simulation. Snapshots taken while
+// the simulation runs may land in the middle of a round, unlike the ones kept
+// with KeepSnapshots, which are taken before a round starts.
+func (s *Simulation) Snapshot() *Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.snapshot()
+}
+
+// KeepSnapshots makes the simulation take a snapshot before every round that
+// is a multiple of every, including the first. Every snapshot holds the state
+// of every city and alien, so keep them sparse on big maps. Zero stops taking
+// them.
+func (s *Simulation) KeepSnapshots(every int) {
+	s.snapshotEvery = every
+}
+
+// Snapshots returns the snapshots kept while the simulation ran, in the order
+// of their rounds
+func (s *Simulation) Snapshots() []*Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]*Snapshot(nil), s.snapshots...)
+}
+
+// Restore rewinds the simulation to a snapshot of the same map. The random
+// moves continue as they would have from the snapshot; set a new config with
+// SetConfig after restoring to continue with another seed. It must not be
+// called while the simulation runs.
+func (s *Simulation) Restore(snap *Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.matches(snap)
+	if err != nil {
+		return err
+	}
+	copy(s.m.graph.destroyed, snap.destroyed)
+	copy(s.m.graph.closed, snap.closed)
+	for i := range s.m.cities {
+		if city := s.m.cities[i].Load(); city != nil {
+			clear(city.aliens)
+		}
+	}
+	for _, as := range snap.aliens {
+		alien := s.m.Aliens[as.id]
+		alien.alive = as.alive
+		alien.position = nil
+		if as.city >= 0 {
+			alien.position = s.m.city(as.city)
+		}
+		if alien.alive && alien.position != nil {
+			alien.position.AddAlien(alien)
+		}
+	}
+	s.round = snap.round
+	s.aliensLeft = snap.aliensLeft
+	s.seed = snap.seed
+	s.rng, s.source = newRNG(snap.rngState)
+	// the living aliens are found again when the simulation runs
+	s.living = nil
+	// the kept snapshots from the restored round on are taken again
+	kept := s.snapshots[:0]
+	for _, earlier := range s.snapshots {
+		if earlier.round < snap.round {
+			kept = append(kept, earlier)
+		}
+	}
+	s.snapshots = kept
+	return nil
+}
+
+// Round returns the round of the snapshot
+func (snap *Snapshot) Round() int {
+	return snap.round
+}
+
+// AliensLeft returns the aliens left in the snapshot
+func (snap *Snapshot) AliensLeft() int {
+	return snap.aliensLeft
+}
+
+// Seed returns the seed of the simulation in the snapshot
+func (snap *Snapshot) Seed() int64 {
+	return snap.seed
+}
+
+// City returns the state of a city in the snapshot
+func (snap *Snapshot) City(name string) (CityState, error) {
+	id, err := snap.graph.CityID(name)
+	if err != nil || id >= snap.cities {
+		return CityState{}, fmt.Errorf("Couldn't find city %v", name)
+	}
+	// the state of the cities and roads of the graph at the snapshot
+	g := *snap.graph
+	g.destroyed, g.closed = snap.destroyed, snap.closed
+	st := graphState(&g, id)
+	for _, as := range snap.aliens {
+		if as.alive && as.city == id {
+			st.Aliens = append(st.Aliens, as.id)
+		}
+	}
+	return st, nil
+}
+
+// Alien returns the state of an alien in the snapshot
+func (snap *Snapshot) Alien(id int) (AlienState, error) {
+	i := sort.Search(len(snap.aliens), func(i int) bool { return snap.aliens[i].id >= id })
+	if i == len(snap.aliens) || snap.aliens[i].id != id {
+		return AlienState{}, fmt.Errorf("Couldn't find alien with id %v", id)
+	}
+	as := snap.aliens[i]
+	st := AlienState{ID: as.id, Alive: as.alive}
+	if as.city >= 0 {
+		st.City = snap.graph.CityName(as.city)
+	}
+	return st, nil
+}
+
+// ----- Unexported functions -----
+
+// snapshot copies the state of the simulation, with the lock already held
+func (s *Simulation) snapshot() *Snapshot {
+	snap := &Snapshot{
+		round:      s.round,
+		aliensLeft: s.aliensLeft,
+		seed:       s.seed,
+		rngState:   s.source.state,
+		graph:      s.m.graph,
+		cities:     s.m.CitiesLen(),
+		destroyed:  slices.Clone(s.m.graph.destroyed),
+		closed:     slices.Clone(s.m.graph.closed),
+		aliens:     make([]alienSnapshot, 0, len(s.m.Aliens)),
+	}
+	for _, id := range s.m.Aliens.IDs() {
+		alien := s.m.Aliens[id]
+		as := alienSnapshot{id: id, city: -1, alive: alien.alive}
+		if alien.position != nil {
+			as.city = alien.position.id
+		}
+		snap.aliens = append(snap.aliens, as)
+	}
+	return snap
+}
+
+// keepSnapshot takes a snapshot if the round is one of the kept ones
+func (s *Simulation) keepSnapshot() {
+	if s.snapshotEvery > 0 && s.round%s.snapshotEvery == 0 {
+		s.mu.Lock()
+		s.snapshots = append(s.snapshots, s.snapshot())
+		s.mu.Unlock()
+	}
+}
+
+// matches checks that the snapshot was taken from the map of the simulation
+func (s *Simulation) matches(snap *Snapshot) error {
+	if snap.cities != s.m.CitiesLen() || len(snap.aliens) != len(s.m.Aliens) {
+		return fmt.Errorf("Snapshot has %v cities and %v aliens, the map has %v cities and %v aliens",
+			snap.cities, len(snap.aliens), s.m.CitiesLen(), len(s.m.Aliens))
+	}
+	if snap.graph != s.m.graph || len(snap.closed) != len(s.m.graph.closed) {
+		return fmt.Errorf("Snapshot was taken from another map")
+	}
+	for _, as := range snap.aliens {
+		_, err := s.m.Aliens.Get(as.id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitMixSource is a SplitMix64 source of random numbers. Its whole state is
+// a single number, so snapshots and checkpoints save it as it is.
+type splitMixSource struct {
+	state uint64
+}
+
+func (src *splitMixSource) Uint64() uint64 {
+	x := mix(src.state)
+	src.state += 0x9e3779b97f4a7c15
+	return x
+}
+
+func (src *splitMixSource) Int63() int64 {
+	return int64(src.Uint64() >> 1)
+}
+
+func (src *splitMixSource) Seed(seed int64) {
+	src.state = uint64(seed)
+}
+
+// newRNG creates the random numbers of a simulation from the state of their
+// source, which is the seed when the simulation starts
+func newRNG(state uint64) (*rand.Rand, *splitMixSource) {
+	source := &splitMixSource{state: state}
+	return rand.New(source), source
+}
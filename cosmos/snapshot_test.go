@@ -0,0 +1,119 @@
+package cosmos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordedSimulation creates a simulation on a ring that keeps its events
+func recordedSimulation(t *testing.T, cfg Config) (*Simulation, *[]Event) {
+	sim := NewSimulation(ringMap(64, 6), 6)
+	sim.SetMetrics(NewMetrics())
+	require.Nil(t, sim.SetConfig(cfg))
+	var events []Event
+	sim.Subscribe(func(e Event) {
+		events = append(events, e)
+	})
+	return sim, &events
+}
+
+// fromRound returns the events of the round and the ones after it
+func fromRound(events []Event, round int) []Event {
+	for i, e := range events {
+		if e.Round >= round {
+			return events[i:]
+		}
+	}
+	return nil
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	for _, workers := range []int{0, 2} {
+		cfg := DefaultConfig()
+		cfg.Seed = 3
+		cfg.MaxRounds = 40
+		cfg.FightThreshold = 3
+		cfg.Workers = workers
+		sim, events := recordedSimulation(t, cfg)
+		sim.KeepSnapshots(10)
+		left, rounds, err := sim.Run()
+		require.Nil(t, err)
+		require.Equal(t, 40, rounds)
+		snapshots := sim.Snapshots()
+		require.Len(t, snapshots, 4)
+		snap := snapshots[2]
+		assert.Equal(t, 20, snap.Round())
+		assert.Equal(t, int64(3), snap.Seed())
+		final := battleState(sim.m)
+		original := append([]Event(nil), fromRound(*events, 20)...)
+
+		// rewinding replays the same battle from the snapshot
+		require.Nil(t, sim.Restore(snap))
+		aliensLeft, round := sim.Progress()
+		assert.Equal(t, snap.AliensLeft(), aliensLeft)
+		assert.Equal(t, 20, round)
+		assert.Nil(t, CheckInvariants(sim.m))
+		*events = nil
+		restoredLeft, restoredRounds, err := sim.Run()
+		require.Nil(t, err)
+		assert.Equal(t, left, restoredLeft)
+		assert.Equal(t, rounds, restoredRounds)
+		assert.Equal(t, original, *events)
+		assert.Equal(t, final, battleState(sim.m))
+		assert.Len(t, sim.Snapshots(), 4)
+
+		// and can continue with another seed
+		require.Nil(t, sim.Restore(snap))
+		cfg.Seed = 4
+		require.Nil(t, sim.SetConfig(cfg))
+		*events = nil
+		_, _, err = sim.Run()
+		require.Nil(t, err)
+		assert.Equal(t, 20, (*events)[0].Round)
+		assert.Nil(t, CheckInvariants(sim.m))
+	}
+}
+
+func TestSnapshotState(t *testing.T) {
+	m := twoCitiesMap()
+	sim := NewSimulation(m, 2)
+	snap := sim.Snapshot()
+
+	// later changes don't reach the snapshot
+	foo, _ := m.GetCity("Foo")
+	assert.Nil(t, fight(0, foo))
+	city, err := snap.City("Foo")
+	require.Nil(t, err)
+	assert.Equal(t, CityState{Name: "Foo", Aliens: []int{0}, Roads: map[Direction]string{East: "Bar"}}, city)
+	alien, err := snap.Alien(0)
+	require.Nil(t, err)
+	assert.Equal(t, AlienState{ID: 0, Alive: true, City: "Foo"}, alien)
+	_, err = snap.City("Qux")
+	assert.EqualError(t, err, "Couldn't find city Qux")
+	_, err = snap.Alien(42)
+	assert.EqualError(t, err, "Couldn't find alien with id 42")
+
+	require.Nil(t, sim.Restore(snap))
+	assert.Equal(t, battleState(twoCitiesMap()), battleState(m))
+	assert.Nil(t, CheckInvariants(m))
+
+	other := NewSimulation(ringMap(3, 1), 1)
+	assert.EqualError(t, other.Restore(snap), "Snapshot has 2 cities and 2 aliens, the map has 3 cities and 1 aliens")
+}
+
+func TestSnapshotOneWayRoads(t *testing.T) {
+	sim := NewSimulation(textMap(t, "Lake east->Mill\nMill north=Bridge\n"), 0)
+	snap := sim.Snapshot()
+	for _, name := range []string{"Lake", "Mill"} {
+		city, err := snap.City(name)
+		require.Nil(t, err)
+		live, err := sim.City(name)
+		require.Nil(t, err)
+		assert.Equal(t, live, city)
+	}
+	city, err := snap.City("Lake")
+	require.Nil(t, err)
+	assert.Equal(t, []Direction{East}, city.OneWay)
+}
@@ -2,98 +2,185 @@ package cosmos
 
 import (
 	"fmt"
-	"strconv"
+	"io"
+	"slices"
+	"sort"
 	"strings"
+	"sync/atomic"
 )
 
 // ========== Cosmos ==========
 
-// Map represents the overall map structure with cities and aliens
+// Map represents the overall map structure with cities and aliens. Its cities
+// and roads are kept in a Graph, so a map takes a few bytes for each of them;
+// the City of a city is only built the first time it is asked for, which in
+// a battle is when an alien gets to it.
 type Map struct {
-	cities       map[string]*City // Array of cities in the map
-	Aliens       Aliens           // Map of aliens in the map.
-	CitiesIDName map[int]string
+	graph  *Graph                 // cities, roads and destroyed cities
+	cities []atomic.Pointer[City] // City of each id, nil until it is needed
+	Aliens Aliens                 // Map of aliens in the map.
 }
 
 // CreateMap creates a new Galaxy
 func CreateMap() *Map {
-	return &Map{
-		cities:       make(map[string]*City),
-		Aliens:       InitAliens(),
-		CitiesIDName: make(map[int]string),
-	}
+	return NewGraph().Map()
 }
 
 // GetCity Gets a city from cities mapping
-func (m Map) GetCity(name string) (*City, error) {
-	var city, ok = m.cities[name]
-	if !ok {
+func (m *Map) GetCity(name string) (*City, error) {
+	id := m.graph.lookupString(name)
+	if id < 0 {
 		return nil, fmt.Errorf("Couldn't find city %v", name)
 	}
-	return city, nil
+	return m.city(id), nil
 }
 
-// SetCity Sets a city to mapping
+// SetCity adds a city to the map, with the next id, along with the roads it
+// already has and the cities they lead to. A city is only in one map, and
+// the map keeps its roads from then on.
 func (m *Map) SetCity(city *City) {
-	m.cities[city.Name()] = city
+	if city.m == m {
+		return
+	}
+	city.m, city.id = m, m.graph.city([]byte(city.name))
+	m.grow()
+	m.cities[city.id].Store(city)
+	roads := city.roads
+	city.roads = nil
+	for _, road := range roads {
+		if road != nil {
+			city.AddRoad(road)
+		}
+	}
+}
+
+// CityName returns the name of the city with the given id. Ids go from 0 to
+// CitiesLen, in the order the cities were added.
+func (m *Map) CityName(id int) string {
+	return m.graph.CityName(id)
+}
+
+// Graph returns the graph that holds the cities and roads of the map, to go
+// through all of them without building a City for each
+func (m *Map) Graph() *Graph {
+	return m.graph
+}
+
+// Directions returns the directions the roads of the map can take
+func (m *Map) Directions() *DirectionSet {
+	return m.graph.directions
+}
+
+// SetDirections sets the directions the roads of the map can take, before
+// reading it
+func (m *Map) SetDirections(set *DirectionSet) {
+	m.graph.setDirections(set)
 }
 
 // CitiesLen return the total amount of cities in the map
-func (m Map) CitiesLen() int {
-	return len(m.cities)
+func (m *Map) CitiesLen() int {
+	return m.graph.CitiesLen()
+}
+
+// Parse reads the cities and roads of a map in the .txt format straight into
+// the map, with the same rules as LoadGraph
+func (m *Map) Parse(r io.Reader) error {
+	err := ReadLines(r, m.graph.parseLine)
+	m.grow()
+	return err
+}
+
+// WriteTo writes the cities that were not destroyed and their available roads
+// in the .txt format
+func (m *Map) WriteTo(w io.Writer) (int64, error) {
+	return m.graph.WriteTo(w)
+}
+
+// ----- Unexported functions -----
+
+// city returns the City of an id, building it if it is the first time. Both
+// the engine and the observers of a simulation call it, so it may run in
+// several goroutines at once.
+func (m *Map) city(id int) *City {
+	if city := m.cities[id].Load(); city != nil {
+		return city
+	}
+	m.cities[id].CompareAndSwap(nil, &City{name: m.graph.CityName(id), m: m, id: id})
+	return m.cities[id].Load()
+}
+
+// grow makes room for the City of every city of the graph
+func (m *Map) grow() {
+	if n := m.graph.CitiesLen(); n > len(m.cities) {
+		m.cities = slices.Grow(m.cities, n-len(m.cities))[:n]
+	}
 }
 
 // ========== City ==========
 
-// City struct definition
+// City is a city of a map. Its roads and whether it is destroyed are kept in
+// the map, and a City only holds its aliens. A new city holds its roads
+// until it is set in a map.
 type City struct {
-	name      string // name of the city
-	roads     Roads  // map of road structs
-	aliens    Aliens // map of alien structs
-	destroyed bool   // boolean to check if the city is destroyed
+	name   string // name of the city
+	m      *Map   // map of the city, nil until it is set in one
+	id     int    // id of the city in its map
+	roads  Roads  // roads of the city until it is set in a map
+	aliens Aliens // map of alien structs, nil until an alien comes
 }
 
 // NewCity creates a new city
 func NewCity(name string) *City {
 	return &City{
-		name:      name,
-		roads:     InitRoads(),
-		aliens:    InitAliens(),
-		destroyed: false,
+		name:   name,
+		roads:  InitRoads(),
+		aliens: InitAliens(),
 	}
 }
 
 // Name returns the name of the city
-func (city City) Name() string {
+func (city *City) Name() string {
 	return city.name
 }
 
-// GetRoads returns all roads from the city
-func (city City) GetRoads() Roads {
-	return city.roads
+// GetRoads returns all roads from the city, indexed by the IntValue of their
+// directions
+func (city *City) GetRoads() Roads {
+	if city.m == nil {
+		return city.roads
+	}
+	roads := make(Roads, city.m.graph.slots)
+	for slot := range roads {
+		roads[slot] = city.road(slot)
+	}
+	return roads
 }
 
-// GetRoad returns a pointer to the road in the desired direction
-func (city City) GetRoad(i int) (*Road, error) {
-	if i < 4 {
-		return city.roads[i], nil
+// GetRoad returns a pointer to the road in the desired direction, nil if the
+// city has none
+func (city *City) GetRoad(i int) (*Road, error) {
+	if i < 0 || i >= len(compass) {
+		return nil, fmt.Errorf("Invalid direction")
+	}
+	if city.m == nil {
+		return city.roads.at(i), nil
 	}
-	return nil, fmt.Errorf("Invalid direction")
+	return city.road(i), nil
 }
 
 // IsDestroyed returns the current status of the city
-func (city City) IsDestroyed() bool {
-	return city.destroyed
+func (city *City) IsDestroyed() bool {
+	return city.m != nil && city.m.graph.IsDestroyed(city.id)
 }
 
 // CountAliens returns the total amount of aliens for the given city
-func (city City) CountAliens() int {
+func (city *City) CountAliens() int {
 	return city.aliens.Len()
 }
 
 // HasFight checks if there's a fight in the current move
 // A fight happens if there's more than 2 aliens in the same city
-func (city City) HasFight() bool {
+func (city *City) HasFight() bool {
 	var totalAliens = city.CountAliens()
 	return totalAliens > 1
 }
@@ -103,6 +190,9 @@ func (city *City) AddAlien(alien *Alien) error {
 	if !alien.IsAlive() {
 		return fmt.Errorf("Alien is not alive")
 	}
+	if city.aliens == nil {
+		city.aliens = InitAliens()
+	}
 	city.aliens.Set(alien.ID(), alien)
 	return nil
 }
@@ -113,47 +203,94 @@ func (city *City) RemoveAlien(id int) error {
 	return err
 }
 
-// AddRoad adds a new road to the city
+// AddRoad adds a new road to the city, in place of the road it had in that
+// direction. The destination of a road of a city in a map is added to the
+// map if it is not in it yet.
 func (city *City) AddRoad(road *Road) error {
-	roads, err := city.roads.AddRoad(road)
-	if err == nil {
-		city.roads = roads
+	if city.m == nil {
+		roads, err := city.roads.AddRoad(road)
+		if err == nil {
+			city.roads = roads
+		}
+		return err
+	}
+	var dir = road.GetDirection()
+	slot := dir.IntValue()
+	if slot < 0 {
+		return fmt.Errorf("Invalid direction: %v", dir)
+	}
+	if road.destination == nil {
+		return fmt.Errorf("Destination city does not exist")
+	}
+	city.m.SetCity(road.destination)
+	city.m.graph.setRoad(city.id, slot, road.destination.id, road.oneWay, road.available)
+	road.inMap = true
+	return nil
+}
+
+// GetIncoming returns the one-way roads from other cities to the city. It
+// goes through the roads of the whole map.
+func (city *City) GetIncoming() []*Road {
+	if city.m == nil {
+		return nil
+	}
+	g := city.m.graph
+	var incoming []*Road
+	for i, to := range g.roads {
+		if int(to) == city.id && g.isOneWay(i) {
+			incoming = append(incoming, city.m.city(i/g.slots).road(i%g.slots))
+		}
+	}
+	return incoming
+}
+
+// road returns the road of a city of a map in a slot, or nil if there is none
+func (city *City) road(slot int) *Road {
+	g := city.m.graph
+	to, available := g.roadAt(city.id, slot)
+	if to < 0 {
+		return nil
+	}
+	return &Road{
+		origin:      city,
+		direction:   compass[slot],
+		destination: city.m.city(to),
+		available:   available,
+		oneWay:      g.isOneWay(g.slots*city.id + slot),
+		inMap:       true,
 	}
-	return err
 }
 
 // ========== Roads ==========
 
-// Roads is a set of 4 roads, one for each direction:
-// North, South, East, West
-type Roads [4]*Road
+// Roads is the set of roads of a city, indexed by the IntValue of their
+// directions. It only grows past the 4 compass directions (North, South, East,
+// West) for cities with roads in the other ones.
+type Roads []*Road
 
-// InitRoads initializes an empty road array of size 4
+// InitRoads initializes an empty road array for the 4 compass directions
 func InitRoads() Roads {
-	return [4]*Road{}
+	return make(Roads, 4)
 }
 
 // AddRoad adds a road to its corresponding position in the array
 func (roads Roads) AddRoad(road *Road) (Roads, error) {
 	var dir = road.GetDirection()
-	if dir == North {
-		roads[0] = road
-	} else if dir == South {
-		roads[1] = road
-	} else if dir == East {
-		roads[2] = road
-	} else if dir == West {
-		roads[3] = road
-	} else {
+	slot := dir.IntValue()
+	if slot < 0 {
 		return roads, fmt.Errorf("Invalid direction: %v", dir)
 	}
+	for len(roads) <= slot {
+		roads = append(roads, nil)
+	}
+	roads[slot] = road
 	return roads, nil
 }
 
 // AvailableRoads filters all roads that are not destroyed from a set
 func (roads Roads) AvailableRoads() int {
 	avaliable := 0
-	for i := 0; i < 4; i++ {
+	for i := 0; i < len(roads); i++ {
 		if roads[i] != nil && roads[i].IsAvailable() {
 			avaliable++
 		}
@@ -163,28 +300,18 @@ func (roads Roads) AvailableRoads() int {
 
 // Destroy a single road in a given direction
 func (roads Roads) Destroy(dir Direction) (Roads, error) {
-	switch dir {
-	case North:
-		err := roads[0].Destroy()
-		return roads, err
-	case South:
-		err := roads[1].Destroy()
-		return roads, err
-	case East:
-		err := roads[2].Destroy()
-		return roads, err
-	case West:
-		err := roads[3].Destroy()
-		return roads, err
-	default:
+	road := roads.at(dir.IntValue())
+	if road == nil {
 		return roads, fmt.Errorf("Invalid direction")
 	}
+	err := road.Destroy()
+	return roads, err
 }
 
 // DestroyAll destroys all the roads of a city
 func (roads Roads) DestroyAll() error {
-	for i := 0; i < 4; i++ {
-		if roads[i] == nil {
+	for i := 0; i < len(roads); i++ {
+		if roads[i] == nil || !roads[i].IsAvailable() {
 			continue
 		}
 		var err = roads[i].Destroy()
@@ -196,9 +323,19 @@ func (roads Roads) DestroyAll() error {
 	return nil
 }
 
+// at returns the road in a slot, or nil if there is none
+func (roads Roads) at(slot int) *Road {
+	if slot < 0 || slot >= len(roads) {
+		return nil
+	}
+	return roads[slot]
+}
+
 // ========== Direction ==========
 
-// Direction is one of [N, S, E, W]
+// Direction is one of the compass directions [N, S, E, W], the diagonal ones
+// [NE, SW, NW, SE] or [Up, Down]. Which of them a map can use is given by
+// its DirectionSet.
 type Direction string
 
 const (
@@ -210,25 +347,41 @@ const (
 	East Direction = "east"
 	// West direction
 	West Direction = "west"
+	// Northeast direction
+	Northeast Direction = "northeast"
+	// Southwest direction
+	Southwest Direction = "southwest"
+	// Northwest direction
+	Northwest Direction = "northwest"
+	// Southeast direction
+	Southeast Direction = "southeast"
+	// Up direction, to the layer above
+	Up Direction = "up"
+	// Down direction, to the layer below
+	Down Direction = "down"
 	// Destroyed city is evaluated as an empty string
 	Destroyed Direction = ""
 )
 
+// compass lists every direction in the order of their slots in Roads. Each
+// direction is next to its opposite, so the opposite of slot i is slot i^1.
+var compass = [...]Direction{North, South, East, West, Northeast, Southwest, Northwest, Southeast, Up, Down}
+
 func (dir Direction) Value() (string, error) {
-	switch dir {
-	case North:
-		return "north", nil
-	case South:
-		return "south", nil
-	case East:
-		return "east", nil
-	case West:
-		return "west", nil
-	case Destroyed:
-		return "", nil
-	default:
-		return "", fmt.Errorf("%v is not a valid direction", dir)
+	if dir == Destroyed || dir.IntValue() >= 0 {
+		return string(dir), nil
+	}
+	return "", fmt.Errorf("%v is not a valid direction", dir)
+}
+
+// Opposite returns the opposite direction, or Destroyed if the direction is
+// not valid
+func (dir Direction) Opposite() Direction {
+	slot := dir.IntValue()
+	if slot < 0 {
+		return Destroyed
 	}
+	return compass[slot^1]
 }
 
 // IntValue gets the corresponding integer value in the array of roads
@@ -242,36 +395,45 @@ func (dir Direction) IntValue() int {
 		return 2
 	case West:
 		return 3
+	case Northeast:
+		return 4
+	case Southwest:
+		return 5
+	case Northwest:
+		return 6
+	case Southeast:
+		return 7
+	case Up:
+		return 8
+	case Down:
+		return 9
 	default:
 		return -1
 	}
 }
 
-// StrToDir converts string to Direction type
+// StrToDir converts string to Direction type. It takes any known direction;
+// DirectionSet.Parse only takes the directions of a set.
 func StrToDir(str string) (Direction, error) {
 	str = strings.ToLower(str)
 	str = strings.TrimSpace(str)
-	if str == "north" || str == " north" {
-		return North, nil
-	} else if str == "south" || str == " south" {
-		return South, nil
-	} else if str == "east" || str == " east" {
-		return East, nil
-	} else if str == "west" || str == " west" {
-		return West, nil
-	} else {
-		return "", fmt.Errorf("String %v is not a valid direction", str)
+	if dir := Direction(str); dir.IntValue() >= 0 {
+		return dir, nil
 	}
+	return "", fmt.Errorf("String %v is not a valid direction", str)
 }
 
 // ========== Road ==========
 
-// Road struct definition
+// Road is a road from a city to another one. The road of a city in a map is
+// the slot of the map that holds it, so its state is the one of the map.
 type Road struct {
 	origin      *City     // origin city
-	direction   Direction // direction is one of the (N, S, E, W) directions
+	direction   Direction // direction from origin to destination
 	destination *City     // destination city
 	available   bool      // available road to move
+	oneWay      bool      // the road has no reverse road
+	inMap       bool      // the road was added to a map, which holds its state
 }
 
 // NewRoad creates a new Road instance
@@ -284,6 +446,13 @@ func NewRoad(cityA *City, dir Direction, cityB *City) *Road {
 	}
 }
 
+// NewOneWayRoad creates a road that only goes from cityA to cityB
+func NewOneWayRoad(cityA *City, dir Direction, cityB *City) *Road {
+	road := NewRoad(cityA, dir, cityB)
+	road.oneWay = true
+	return road
+}
+
 // Origin city
 func (road Road) Origin() *City {
 	return road.origin
@@ -299,20 +468,17 @@ func (road Road) GetDirection() Direction {
 	return road.direction
 }
 
+// IsOneWay checks if the road only goes from origin to destination
+func (road Road) IsOneWay() bool {
+	if g, i := road.slot(); g != nil {
+		return g.isOneWay(i)
+	}
+	return road.oneWay
+}
+
 // OppositeDirection gets the opossite direction of the road (i.e. from destination to origin)
 func (road Road) OppositeDirection() Direction {
-	switch road.direction {
-	case North:
-		return South
-	case South:
-		return North
-	case East:
-		return West
-	case West:
-		return East
-	default:
-		return Destroyed
-	}
+	return road.direction.Opposite()
 }
 
 // Destroy destroys the road
@@ -321,14 +487,38 @@ func (road *Road) Destroy() error {
 		return fmt.Errorf("Road is currently destroyed")
 	}
 	road.available = false
+	if g, i := road.slot(); g != nil {
+		setBit(g.closed, i, true)
+	}
 	return nil
 }
 
 // IsAvailable checks if the road is not destroyed
 func (road Road) IsAvailable() bool {
+	if g, i := road.slot(); g != nil {
+		return g.available(i)
+	}
 	return road.available
 }
 
+// slot returns the graph of the map of the road and the slot of the road in
+// it, or nil if the road is not in a map
+func (road Road) slot() (*Graph, int) {
+	if !road.inMap || road.origin == nil || road.origin.m == nil || road.destination == nil || road.destination.m != road.origin.m {
+		return nil, -1
+	}
+	g := road.origin.m.graph
+	slot := road.direction.IntValue()
+	if slot < 0 || slot >= g.slots {
+		return nil, -1
+	}
+	i := g.slots*road.origin.id + slot
+	if int(g.roads[i]) != road.destination.id {
+		return nil, -1
+	}
+	return g, i
+}
+
 // ========== Aliens ==========
 
 // Aliens is a set of aliens
@@ -393,6 +583,16 @@ func (aliens Aliens) Len() int {
 	return len(aliens)
 }
 
+// IDs returns the sorted ids of the aliens in the mapping
+func (aliens Aliens) IDs() []int {
+	var ids = make([]int, 0, len(aliens))
+	for id := range aliens {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
 // ----- Unexported functions -----
 
 // Set value of alien
@@ -441,7 +641,7 @@ func (alien Alien) GetPosition() *City {
 func (alien *Alien) setPosition(city *City) error {
 	var name = city.Name()
 	if alien.position.Name() == name {
-		return fmt.Errorf("Alien " + strconv.Itoa(alien.ID()) + " is already in city " + name)
+		return fmt.Errorf("Alien %v is already in city %v", alien.ID(), name)
 	}
 	alien.position = city
 	return nil
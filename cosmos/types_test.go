@@ -28,10 +28,22 @@ func TestAddRoad(t *testing.T) {
 	rroad, err := city.GetRoad(3)
 	assert.Nil(t, err)
 	assert.Equal(t, road, rroad)
-	rroad, err = city.GetRoad(5) // invalid road
+	rroad, err = city.GetRoad(5) // no road to the southwest
+	assert.Nil(t, rroad)
+	assert.Nil(t, err)
+	rroad, err = city.GetRoad(len(compass)) // invalid road
 	assert.Nil(t, rroad)
 	assert.Error(t, err)
 	assert.Equal(t, city.roads, city.GetRoads())
+
+	// the roads of a city in a map are the ones of the map
+	CreateMap().SetCity(city)
+	assert.Nil(t, city.roads)
+	rroad, err = city.GetRoad(3)
+	assert.Nil(t, err)
+	assert.Equal(t, road, rroad)
+	assert.Equal(t, 1, city.GetRoads().AvailableRoads())
+	assert.Error(t, city.AddRoad(NewRoad(city, "upward", otherCity)))
 }
 
 func TestAddAlien(t *testing.T) {
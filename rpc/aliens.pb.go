@@ -0,0 +1,617 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: aliens.proto
+
+package rpc
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type LoadMapRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Map           string                 `protobuf:"bytes,1,opt,name=map,proto3" json:"map,omitempty"`               // contents of the map in the .txt format
+	Directions    string                 `protobuf:"bytes,2,opt,name=directions,proto3" json:"directions,omitempty"` // directions of its roads: 4 (the default), 8, hex or 3d
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LoadMapRequest) Reset() {
+	*x = LoadMapRequest{}
+	mi := &file_aliens_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LoadMapRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LoadMapRequest) ProtoMessage() {}
+
+func (x *LoadMapRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_aliens_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LoadMapRequest.ProtoReflect.Descriptor instead.
+func (*LoadMapRequest) Descriptor() ([]byte, []int) {
+	return file_aliens_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *LoadMapRequest) GetMap() string {
+	if x != nil {
+		return x.Map
+	}
+	return ""
+}
+
+func (x *LoadMapRequest) GetDirections() string {
+	if x != nil {
+		return x.Directions
+	}
+	return ""
+}
+
+type LoadMapResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MapId         string                 `protobuf:"bytes,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
+	Cities        int32                  `protobuf:"varint,2,opt,name=cities,proto3" json:"cities,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LoadMapResponse) Reset() {
+	*x = LoadMapResponse{}
+	mi := &file_aliens_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LoadMapResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LoadMapResponse) ProtoMessage() {}
+
+func (x *LoadMapResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_aliens_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LoadMapResponse.ProtoReflect.Descriptor instead.
+func (*LoadMapResponse) Descriptor() ([]byte, []int) {
+	return file_aliens_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *LoadMapResponse) GetMapId() string {
+	if x != nil {
+		return x.MapId
+	}
+	return ""
+}
+
+func (x *LoadMapResponse) GetCities() int32 {
+	if x != nil {
+		return x.Cities
+	}
+	return 0
+}
+
+type UnloadMapRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MapId         string                 `protobuf:"bytes,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnloadMapRequest) Reset() {
+	*x = UnloadMapRequest{}
+	mi := &file_aliens_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnloadMapRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnloadMapRequest) ProtoMessage() {}
+
+func (x *UnloadMapRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_aliens_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnloadMapRequest.ProtoReflect.Descriptor instead.
+func (*UnloadMapRequest) Descriptor() ([]byte, []int) {
+	return file_aliens_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *UnloadMapRequest) GetMapId() string {
+	if x != nil {
+		return x.MapId
+	}
+	return ""
+}
+
+type UnloadMapResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnloadMapResponse) Reset() {
+	*x = UnloadMapResponse{}
+	mi := &file_aliens_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnloadMapResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnloadMapResponse) ProtoMessage() {}
+
+func (x *UnloadMapResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_aliens_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnloadMapResponse.ProtoReflect.Descriptor instead.
+func (*UnloadMapResponse) Descriptor() ([]byte, []int) {
+	return file_aliens_proto_rawDescGZIP(), []int{3}
+}
+
+type SimulateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MapId         string                 `protobuf:"bytes,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
+	Aliens        int32                  `protobuf:"varint,2,opt,name=aliens,proto3" json:"aliens,omitempty"` // number of aliens placed in the map
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SimulateRequest) Reset() {
+	*x = SimulateRequest{}
+	mi := &file_aliens_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SimulateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SimulateRequest) ProtoMessage() {}
+
+func (x *SimulateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_aliens_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SimulateRequest.ProtoReflect.Descriptor instead.
+func (*SimulateRequest) Descriptor() ([]byte, []int) {
+	return file_aliens_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SimulateRequest) GetMapId() string {
+	if x != nil {
+		return x.MapId
+	}
+	return ""
+}
+
+func (x *SimulateRequest) GetAliens() int32 {
+	if x != nil {
+		return x.Aliens
+	}
+	return 0
+}
+
+type SimulateResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	AliensLeft    int32                  `protobuf:"varint,1,opt,name=aliens_left,json=aliensLeft,proto3" json:"aliens_left,omitempty"`
+	Rounds        int32                  `protobuf:"varint,2,opt,name=rounds,proto3" json:"rounds,omitempty"`
+	Map           string                 `protobuf:"bytes,3,opt,name=map,proto3" json:"map,omitempty"` // surviving map in the .txt format
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SimulateResponse) Reset() {
+	*x = SimulateResponse{}
+	mi := &file_aliens_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SimulateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SimulateResponse) ProtoMessage() {}
+
+func (x *SimulateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_aliens_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SimulateResponse.ProtoReflect.Descriptor instead.
+func (*SimulateResponse) Descriptor() ([]byte, []int) {
+	return file_aliens_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SimulateResponse) GetAliensLeft() int32 {
+	if x != nil {
+		return x.AliensLeft
+	}
+	return 0
+}
+
+func (x *SimulateResponse) GetRounds() int32 {
+	if x != nil {
+		return x.Rounds
+	}
+	return 0
+}
+
+func (x *SimulateResponse) GetMap() string {
+	if x != nil {
+		return x.Map
+	}
+	return ""
+}
+
+type Event struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"` // one of move, fight or destroy
+	Round         int32                  `protobuf:"varint,2,opt,name=round,proto3" json:"round,omitempty"`
+	Aliens        []int32                `protobuf:"varint,3,rep,packed,name=aliens,proto3" json:"aliens,omitempty"`
+	City          string                 `protobuf:"bytes,4,opt,name=city,proto3" json:"city,omitempty"`
+	From          string                 `protobuf:"bytes,5,opt,name=from,proto3" json:"from,omitempty"`
+	Direction     string                 `protobuf:"bytes,6,opt,name=direction,proto3" json:"direction,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_aliens_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_aliens_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_aliens_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Event) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Event) GetRound() int32 {
+	if x != nil {
+		return x.Round
+	}
+	return 0
+}
+
+func (x *Event) GetAliens() []int32 {
+	if x != nil {
+		return x.Aliens
+	}
+	return nil
+}
+
+func (x *Event) GetCity() string {
+	if x != nil {
+		return x.City
+	}
+	return ""
+}
+
+func (x *Event) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *Event) GetDirection() string {
+	if x != nil {
+		return x.Direction
+	}
+	return ""
+}
+
+type BatchSimulateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MapId         string                 `protobuf:"bytes,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
+	Aliens        int32                  `protobuf:"varint,2,opt,name=aliens,proto3" json:"aliens,omitempty"`
+	Runs          int32                  `protobuf:"varint,3,opt,name=runs,proto3" json:"runs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchSimulateRequest) Reset() {
+	*x = BatchSimulateRequest{}
+	mi := &file_aliens_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchSimulateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchSimulateRequest) ProtoMessage() {}
+
+func (x *BatchSimulateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_aliens_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchSimulateRequest.ProtoReflect.Descriptor instead.
+func (*BatchSimulateRequest) Descriptor() ([]byte, []int) {
+	return file_aliens_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *BatchSimulateRequest) GetMapId() string {
+	if x != nil {
+		return x.MapId
+	}
+	return ""
+}
+
+func (x *BatchSimulateRequest) GetAliens() int32 {
+	if x != nil {
+		return x.Aliens
+	}
+	return 0
+}
+
+func (x *BatchSimulateRequest) GetRuns() int32 {
+	if x != nil {
+		return x.Runs
+	}
+	return 0
+}
+
+type BatchSimulateResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Results       []*SimulateResponse    `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchSimulateResponse) Reset() {
+	*x = BatchSimulateResponse{}
+	mi := &file_aliens_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchSimulateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchSimulateResponse) ProtoMessage() {}
+
+func (x *BatchSimulateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_aliens_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchSimulateResponse.ProtoReflect.Descriptor instead.
+func (*BatchSimulateResponse) Descriptor() ([]byte, []int) {
+	return file_aliens_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *BatchSimulateResponse) GetResults() []*SimulateResponse {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+var File_aliens_proto protoreflect.FileDescriptor
+
+const file_aliens_proto_rawDesc = "" +
+	"\n" +
+	"\faliens.proto\x12\x06aliens\"B\n" +
+	"\x0eLoadMapRequest\x12\x10\n" +
+	"\x03map\x18\x01 \x01(\tR\x03map\x12\x1e\n" +
+	"\n" +
+	"directions\x18\x02 \x01(\tR\n" +
+	"directions\"@\n" +
+	"\x0fLoadMapResponse\x12\x15\n" +
+	"\x06map_id\x18\x01 \x01(\tR\x05mapId\x12\x16\n" +
+	"\x06cities\x18\x02 \x01(\x05R\x06cities\")\n" +
+	"\x10UnloadMapRequest\x12\x15\n" +
+	"\x06map_id\x18\x01 \x01(\tR\x05mapId\"\x13\n" +
+	"\x11UnloadMapResponse\"@\n" +
+	"\x0fSimulateRequest\x12\x15\n" +
+	"\x06map_id\x18\x01 \x01(\tR\x05mapId\x12\x16\n" +
+	"\x06aliens\x18\x02 \x01(\x05R\x06aliens\"]\n" +
+	"\x10SimulateResponse\x12\x1f\n" +
+	"\valiens_left\x18\x01 \x01(\x05R\n" +
+	"aliensLeft\x12\x16\n" +
+	"\x06rounds\x18\x02 \x01(\x05R\x06rounds\x12\x10\n" +
+	"\x03map\x18\x03 \x01(\tR\x03map\"\x8f\x01\n" +
+	"\x05Event\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x14\n" +
+	"\x05round\x18\x02 \x01(\x05R\x05round\x12\x16\n" +
+	"\x06aliens\x18\x03 \x03(\x05R\x06aliens\x12\x12\n" +
+	"\x04city\x18\x04 \x01(\tR\x04city\x12\x12\n" +
+	"\x04from\x18\x05 \x01(\tR\x04from\x12\x1c\n" +
+	"\tdirection\x18\x06 \x01(\tR\tdirection\"Y\n" +
+	"\x14BatchSimulateRequest\x12\x15\n" +
+	"\x06map_id\x18\x01 \x01(\tR\x05mapId\x12\x16\n" +
+	"\x06aliens\x18\x02 \x01(\x05R\x06aliens\x12\x12\n" +
+	"\x04runs\x18\x03 \x01(\x05R\x04runs\"K\n" +
+	"\x15BatchSimulateResponse\x122\n" +
+	"\aresults\x18\x01 \x03(\v2\x18.aliens.SimulateResponseR\aresults2\xd3\x02\n" +
+	"\tSimulator\x12:\n" +
+	"\aLoadMap\x12\x16.aliens.LoadMapRequest\x1a\x17.aliens.LoadMapResponse\x12@\n" +
+	"\tUnloadMap\x12\x18.aliens.UnloadMapRequest\x1a\x19.aliens.UnloadMapResponse\x12=\n" +
+	"\bSimulate\x12\x17.aliens.SimulateRequest\x1a\x18.aliens.SimulateResponse\x12;\n" +
+	"\x0fWatchSimulation\x12\x17.aliens.SimulateRequest\x1a\r.aliens.Event0\x01\x12L\n" +
+	"\rBatchSimulate\x12\x1c.aliens.BatchSimulateRequest\x1a\x1d.aliens.BatchSimulateResponseB%Z#github.com/fedekunze/alien_task/rpcb\x06proto3"
+
+var (
+	file_aliens_proto_rawDescOnce sync.Once
+	file_aliens_proto_rawDescData []byte
+)
+
+func file_aliens_proto_rawDescGZIP() []byte {
+	file_aliens_proto_rawDescOnce.Do(func() {
+		file_aliens_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_aliens_proto_rawDesc), len(file_aliens_proto_rawDesc)))
+	})
+	return file_aliens_proto_rawDescData
+}
+
+var file_aliens_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_aliens_proto_goTypes = []any{
+	(*LoadMapRequest)(nil),        // 0: aliens.LoadMapRequest
+	(*LoadMapResponse)(nil),       // 1: aliens.LoadMapResponse
+	(*UnloadMapRequest)(nil),      // 2: aliens.UnloadMapRequest
+	(*UnloadMapResponse)(nil),     // 3: aliens.UnloadMapResponse
+	(*SimulateRequest)(nil),       // 4: aliens.SimulateRequest
+	(*SimulateResponse)(nil),      // 5: aliens.SimulateResponse
+	(*Event)(nil),                 // 6: aliens.Event
+	(*BatchSimulateRequest)(nil),  // 7: aliens.BatchSimulateRequest
+	(*BatchSimulateResponse)(nil), // 8: aliens.BatchSimulateResponse
+}
+var file_aliens_proto_depIdxs = []int32{
+	5, // 0: aliens.BatchSimulateResponse.results:type_name -> aliens.SimulateResponse
+	0, // 1: aliens.Simulator.LoadMap:input_type -> aliens.LoadMapRequest
+	2, // 2: aliens.Simulator.UnloadMap:input_type -> aliens.UnloadMapRequest
+	4, // 3: aliens.Simulator.Simulate:input_type -> aliens.SimulateRequest
+	4, // 4: aliens.Simulator.WatchSimulation:input_type -> aliens.SimulateRequest
+	7, // 5: aliens.Simulator.BatchSimulate:input_type -> aliens.BatchSimulateRequest
+	1, // 6: aliens.Simulator.LoadMap:output_type -> aliens.LoadMapResponse
+	3, // 7: aliens.Simulator.UnloadMap:output_type -> aliens.UnloadMapResponse
+	5, // 8: aliens.Simulator.Simulate:output_type -> aliens.SimulateResponse
+	6, // 9: aliens.Simulator.WatchSimulation:output_type -> aliens.Event
+	8, // 10: aliens.Simulator.BatchSimulate:output_type -> aliens.BatchSimulateResponse
+	6, // [6:11] is the sub-list for method output_type
+	1, // [1:6] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_aliens_proto_init() }
+func file_aliens_proto_init() {
+	if File_aliens_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_aliens_proto_rawDesc), len(file_aliens_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_aliens_proto_goTypes,
+		DependencyIndexes: file_aliens_proto_depIdxs,
+		MessageInfos:      file_aliens_proto_msgTypes,
+	}.Build()
+	File_aliens_proto = out.File
+	file_aliens_proto_goTypes = nil
+	file_aliens_proto_depIdxs = nil
+}
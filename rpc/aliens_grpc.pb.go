@@ -0,0 +1,291 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: aliens.proto
+
+package rpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Simulator_LoadMap_FullMethodName         = "/aliens.Simulator/LoadMap"
+	Simulator_UnloadMap_FullMethodName       = "/aliens.Simulator/UnloadMap"
+	Simulator_Simulate_FullMethodName        = "/aliens.Simulator/Simulate"
+	Simulator_WatchSimulation_FullMethodName = "/aliens.Simulator/WatchSimulation"
+	Simulator_BatchSimulate_FullMethodName   = "/aliens.Simulator/BatchSimulate"
+)
+
+// SimulatorClient is the client API for Simulator service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Simulator runs battles of aliens over maps loaded into the server
+type SimulatorClient interface {
+	// LoadMap parses a map in the .txt format and stores it in the server
+	LoadMap(ctx context.Context, in *LoadMapRequest, opts ...grpc.CallOption) (*LoadMapResponse, error)
+	// UnloadMap removes a loaded map from the server
+	UnloadMap(ctx context.Context, in *UnloadMapRequest, opts ...grpc.CallOption) (*UnloadMapResponse, error)
+	// Simulate runs a single battle over a loaded map
+	Simulate(ctx context.Context, in *SimulateRequest, opts ...grpc.CallOption) (*SimulateResponse, error)
+	// WatchSimulation runs a single battle and streams its events
+	WatchSimulation(ctx context.Context, in *SimulateRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
+	// BatchSimulate runs several independent battles over the same map
+	BatchSimulate(ctx context.Context, in *BatchSimulateRequest, opts ...grpc.CallOption) (*BatchSimulateResponse, error)
+}
+
+type simulatorClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSimulatorClient(cc grpc.ClientConnInterface) SimulatorClient {
+	return &simulatorClient{cc}
+}
+
+func (c *simulatorClient) LoadMap(ctx context.Context, in *LoadMapRequest, opts ...grpc.CallOption) (*LoadMapResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LoadMapResponse)
+	err := c.cc.Invoke(ctx, Simulator_LoadMap_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) UnloadMap(ctx context.Context, in *UnloadMapRequest, opts ...grpc.CallOption) (*UnloadMapResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UnloadMapResponse)
+	err := c.cc.Invoke(ctx, Simulator_UnloadMap_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) Simulate(ctx context.Context, in *SimulateRequest, opts ...grpc.CallOption) (*SimulateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SimulateResponse)
+	err := c.cc.Invoke(ctx, Simulator_Simulate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) WatchSimulation(ctx context.Context, in *SimulateRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Simulator_ServiceDesc.Streams[0], Simulator_WatchSimulation_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SimulateRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Simulator_WatchSimulationClient = grpc.ServerStreamingClient[Event]
+
+func (c *simulatorClient) BatchSimulate(ctx context.Context, in *BatchSimulateRequest, opts ...grpc.CallOption) (*BatchSimulateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BatchSimulateResponse)
+	err := c.cc.Invoke(ctx, Simulator_BatchSimulate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SimulatorServer is the server API for Simulator service.
+// All implementations must embed UnimplementedSimulatorServer
+// for forward compatibility.
+//
+// Simulator runs battles of aliens over maps loaded into the server
+type SimulatorServer interface {
+	// LoadMap parses a map in the .txt format and stores it in the server
+	LoadMap(context.Context, *LoadMapRequest) (*LoadMapResponse, error)
+	// UnloadMap removes a loaded map from the server
+	UnloadMap(context.Context, *UnloadMapRequest) (*UnloadMapResponse, error)
+	// Simulate runs a single battle over a loaded map
+	Simulate(context.Context, *SimulateRequest) (*SimulateResponse, error)
+	// WatchSimulation runs a single battle and streams its events
+	WatchSimulation(*SimulateRequest, grpc.ServerStreamingServer[Event]) error
+	// BatchSimulate runs several independent battles over the same map
+	BatchSimulate(context.Context, *BatchSimulateRequest) (*BatchSimulateResponse, error)
+	mustEmbedUnimplementedSimulatorServer()
+}
+
+// UnimplementedSimulatorServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedSimulatorServer struct{}
+
+func (UnimplementedSimulatorServer) LoadMap(context.Context, *LoadMapRequest) (*LoadMapResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LoadMap not implemented")
+}
+func (UnimplementedSimulatorServer) UnloadMap(context.Context, *UnloadMapRequest) (*UnloadMapResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnloadMap not implemented")
+}
+func (UnimplementedSimulatorServer) Simulate(context.Context, *SimulateRequest) (*SimulateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Simulate not implemented")
+}
+func (UnimplementedSimulatorServer) WatchSimulation(*SimulateRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchSimulation not implemented")
+}
+func (UnimplementedSimulatorServer) BatchSimulate(context.Context, *BatchSimulateRequest) (*BatchSimulateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchSimulate not implemented")
+}
+func (UnimplementedSimulatorServer) mustEmbedUnimplementedSimulatorServer() {}
+func (UnimplementedSimulatorServer) testEmbeddedByValue()                   {}
+
+// UnsafeSimulatorServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SimulatorServer will
+// result in compilation errors.
+type UnsafeSimulatorServer interface {
+	mustEmbedUnimplementedSimulatorServer()
+}
+
+func RegisterSimulatorServer(s grpc.ServiceRegistrar, srv SimulatorServer) {
+	// If the following call pancis, it indicates UnimplementedSimulatorServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Simulator_ServiceDesc, srv)
+}
+
+func _Simulator_LoadMap_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoadMapRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).LoadMap(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_LoadMap_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).LoadMap(ctx, req.(*LoadMapRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_UnloadMap_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnloadMapRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).UnloadMap(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_UnloadMap_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).UnloadMap(ctx, req.(*UnloadMapRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_Simulate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SimulateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).Simulate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_Simulate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).Simulate(ctx, req.(*SimulateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_WatchSimulation_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SimulateRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SimulatorServer).WatchSimulation(m, &grpc.GenericServerStream[SimulateRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Simulator_WatchSimulationServer = grpc.ServerStreamingServer[Event]
+
+func _Simulator_BatchSimulate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchSimulateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).BatchSimulate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_BatchSimulate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).BatchSimulate(ctx, req.(*BatchSimulateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Simulator_ServiceDesc is the grpc.ServiceDesc for Simulator service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Simulator_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "aliens.Simulator",
+	HandlerType: (*SimulatorServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "LoadMap",
+			Handler:    _Simulator_LoadMap_Handler,
+		},
+		{
+			MethodName: "UnloadMap",
+			Handler:    _Simulator_UnloadMap_Handler,
+		},
+		{
+			MethodName: "Simulate",
+			Handler:    _Simulator_Simulate_Handler,
+		},
+		{
+			MethodName: "BatchSimulate",
+			Handler:    _Simulator_BatchSimulate_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchSimulation",
+			Handler:       _Simulator_WatchSimulation_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "aliens.proto",
+}
@@ -0,0 +1,7 @@
+/*
+Package rpc contains the protobuf definition of the gRPC simulator service
+and its generated Go stubs. The service itself is implemented in package cmd.
+*/
+package rpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative aliens.proto